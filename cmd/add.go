@@ -4,6 +4,8 @@
 package cmd
 
 import (
+	"time"
+
 	"github.com/cisco-open/grabit/internal"
 	"github.com/spf13/cobra"
 )
@@ -13,6 +15,19 @@ func init() {
 	addCmd.Flags().String("algo", internal.RecommendedAlgo, "Integrity algorithm")
 	addCmd.Flags().String("filename", "", "Target file name to use when downloading the resource")
 	addCmd.Flags().StringArray("tag", []string{}, "Resource tags")
+	addCmd.Flags().StringArray("header", []string{}, "HTTP header sent when downloading the resource ('Name: value'), ${VAR} references to allowed environment variables are resolved at download time")
+	addCmd.Flags().Bool("extract", false, "Extract the resource, a tar or gzipped tar archive, to a directory when downloading it and record the integrity of its content")
+	addCmd.Flags().String("signature", "", "Url of a detached signature (minisign, OpenPGP or base64 signature) verified when adding and downloading the resource")
+	addCmd.Flags().String("public-key", "", "Public key, or path to a public key file, trusted to sign the resource (default: keys of the keyring)")
+	addCmd.Flags().String("checksums-url", "", "Url of a published checksum file (e.g. SHA256SUMS) the resource must match")
+	addCmd.Flags().String("name", "", "Name of the resource")
+	addCmd.Flags().String("version", "", "Version of the resource")
+	addCmd.Flags().String("homepage", "", "Homepage of the resource")
+	addCmd.Flags().String("description", "", "Description of the resource")
+	addCmd.Flags().String("license", "", "License of the resource (SPDX expression) reported in the SBOM")
+	addCmd.Flags().String("supplier", "", "Supplier of the resource reported in the SBOM")
+	addOutputFlag(addCmd)
+	addCmd.Flags().String("mode", "", "Optional permissions for the downloaded file (e.g. '755'), overrides 'download --perm'")
 }
 
 var addCmd = &cobra.Command{
@@ -23,18 +38,58 @@ var addCmd = &cobra.Command{
 }
 
 func runAdd(cmd *cobra.Command, args []string) {
+	jsonOutput(cmd)
 	lockFile, err := cmd.Flags().GetString("lock-file")
 	FatalIfNotNil(err)
+	release, err := internal.AcquireLock(lockFile)
+	FatalIfNotNil(err)
+	defer release()
 	lock, err := internal.NewLock(lockFile, true)
 	FatalIfNotNil(err)
 	algo, err := cmd.Flags().GetString("algo")
 	FatalIfNotNil(err)
-	tags, err := cmd.Flags().GetStringArray("tag")
+	var opts internal.ResourceOptions
+	opts.Tags, err = cmd.Flags().GetStringArray("tag")
+	FatalIfNotNil(err)
+	opts.Filename, err = cmd.Flags().GetString("filename")
+	FatalIfNotNil(err)
+	opts.Mode, err = cmd.Flags().GetString("mode")
+	FatalIfNotNil(err)
+	headerDefs, err := cmd.Flags().GetStringArray("header")
+	FatalIfNotNil(err)
+	opts.Headers, err = internal.ParseHeaders(headerDefs)
+	FatalIfNotNil(err)
+	opts.Extract, err = cmd.Flags().GetBool("extract")
+	FatalIfNotNil(err)
+	opts.ChecksumsUrl, err = cmd.Flags().GetString("checksums-url")
+	FatalIfNotNil(err)
+	opts.Signature, err = cmd.Flags().GetString("signature")
+	FatalIfNotNil(err)
+	publicKey, err := cmd.Flags().GetString("public-key")
+	FatalIfNotNil(err)
+	opts.PublicKey, err = internal.ReadPublicKey(publicKey)
+	FatalIfNotNil(err)
+	opts.Name, err = cmd.Flags().GetString("name")
+	FatalIfNotNil(err)
+	opts.Version, err = cmd.Flags().GetString("version")
+	FatalIfNotNil(err)
+	opts.Homepage, err = cmd.Flags().GetString("homepage")
 	FatalIfNotNil(err)
-	filename, err := cmd.Flags().GetString("filename")
+	opts.Description, err = cmd.Flags().GetString("description")
 	FatalIfNotNil(err)
-	err = lock.AddResource(args, algo, tags, filename)
+	opts.License, err = cmd.Flags().GetString("license")
 	FatalIfNotNil(err)
-	err = lock.Save()
+	opts.Supplier, err = cmd.Flags().GetString("supplier")
 	FatalIfNotNil(err)
+	result := internal.ResourceResult{Resource: internal.Resource{Urls: args}, Url: args[0], Started: time.Now()}
+	err = lock.AddResource(args, algo, opts)
+	if err == nil {
+		err = lock.Save()
+	}
+	result.Finished = time.Now()
+	result.Err = err
+	if r, ok := lock.Resource(args[0]); ok && err == nil {
+		result.Resource = r
+	}
+	reportResults(cmd, []internal.ResourceResult{result}, err)
 }
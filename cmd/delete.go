@@ -22,10 +22,14 @@ var delCmd = &cobra.Command{
 func runDel(cmd *cobra.Command, args []string) {
 	lockFile, err := cmd.Flags().GetString("lock-file")
 	FatalIfNotNil(err)
+	release, err := internal.AcquireLock(lockFile)
+	FatalIfNotNil(err)
+	defer release()
 	lock, err := internal.NewLock(lockFile, false)
 	FatalIfNotNil(err)
 	for _, r := range args {
-		lock.DeleteResource(r)
+		err = lock.DeleteResource(r)
+		FatalIfNotNil(err)
 	}
 	err = lock.Save()
 	FatalIfNotNil(err)
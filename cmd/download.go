@@ -4,6 +4,8 @@
 package cmd
 
 import (
+	"os"
+
 	"github.com/cisco-open/grabit/internal"
 	"github.com/spf13/cobra"
 )
@@ -14,6 +16,14 @@ func init() {
 	downloadCmd.Flags().StringArray("tag", []string{}, "Only download the resources with the given tag")
 	downloadCmd.Flags().StringArray("notag", []string{}, "Only download the resources without the given tag")
 	downloadCmd.Flags().String("perm", "", "Optional permissions for the downloaded files (e.g. '644')")
+	downloadCmd.Flags().String("link", "", "Keep the files in a shared store and install them in the target directory using the given mode (copy, symlink, hardlink, reflink), files with permissions set by --perm or their resource are copied instead of linked")
+	downloadCmd.Flags().Bool("require-signed-lock", false, "Refuse to download if the lock file is not signed by a trusted key")
+	downloadCmd.Flags().StringArray("trusted-key", []string{}, "PEM public key trusted to sign the lock file")
+	downloadCmd.Flags().String("attestation", "", "Write an in-toto provenance statement describing the downloaded files to the given path")
+	downloadCmd.Flags().String("report", "", "Write a JSON report of the download to the given path")
+	downloadCmd.Flags().Bool("no-progress", false, "Do not display the progress of the downloads")
+	addOutputFlag(downloadCmd)
+	downloadCmd.Flags().String("store", internal.DefaultStoreDir(), "Shared store directory used with --link (default: $GRABIT_STORE or the user cache directory)")
 }
 
 var downloadCmd = &cobra.Command{
@@ -24,10 +34,19 @@ var downloadCmd = &cobra.Command{
 }
 
 func runFetch(cmd *cobra.Command, args []string) {
+	jsonOutput(cmd)
 	lockFile, err := cmd.Flags().GetString("lock-file")
 	FatalIfNotNil(err)
 	lock, err := internal.NewLock(lockFile, false)
 	FatalIfNotNil(err)
+	requireSigned, err := cmd.Flags().GetBool("require-signed-lock")
+	FatalIfNotNil(err)
+	if requireSigned {
+		trustedKeys, err := cmd.Flags().GetStringArray("trusted-key")
+		FatalIfNotNil(err)
+		err = lock.VerifySignature(trustedKeys)
+		FatalIfNotNil(err)
+	}
 	dir, err := cmd.Flags().GetString("dir")
 	FatalIfNotNil(err)
 	tags, err := cmd.Flags().GetStringArray("tag")
@@ -36,6 +55,33 @@ func runFetch(cmd *cobra.Command, args []string) {
 	FatalIfNotNil(err)
 	perm, err := cmd.Flags().GetString("perm")
 	FatalIfNotNil(err)
-	err = lock.Download(dir, tags, notags, perm)
+	link, err := cmd.Flags().GetString("link")
+	FatalIfNotNil(err)
+	var store *internal.Store
+	if link != "" {
+		storeDir, err := cmd.Flags().GetString("store")
+		FatalIfNotNil(err)
+		store, err = internal.NewStore(storeDir, link)
+		FatalIfNotNil(err)
+	}
+	attestation, err := cmd.Flags().GetString("attestation")
+	FatalIfNotNil(err)
+	noProgress, err := cmd.Flags().GetBool("no-progress")
 	FatalIfNotNil(err)
+	stopProgress := func() {}
+	if !noProgress {
+		stopProgress = startProgress()
+	}
+	results, err := lock.Download(dir, tags, notags, perm, store)
+	stopProgress()
+	reportResults(cmd, results, err)
+	if attestation != "" {
+		file, err := os.Create(attestation)
+		FatalIfNotNil(err)
+		err = lock.WriteAttestation(file, dir, results)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		FatalIfNotNil(err)
+	}
 }
@@ -0,0 +1,47 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cisco-open/grabit/internal"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(fmtCmd)
+	fmtCmd.Flags().Bool("check", false, "Only check that the lock file is formatted and fail if it is not")
+}
+
+var fmtCmd = &cobra.Command{
+	Use:   "fmt",
+	Short: "Rewrite the lock file in its canonical form",
+	Args:  cobra.NoArgs,
+	Run:   runFmt,
+}
+
+func runFmt(cmd *cobra.Command, args []string) {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	FatalIfNotNil(err)
+	check, err := cmd.Flags().GetBool("check")
+	FatalIfNotNil(err)
+	if !check {
+		release, err := internal.AcquireLock(lockFile)
+		FatalIfNotNil(err)
+		defer release()
+	}
+	lock, err := internal.NewLock(lockFile, false)
+	FatalIfNotNil(err)
+	if check {
+		formatted, err := lock.IsFormatted()
+		FatalIfNotNil(err)
+		if !formatted {
+			FatalIfNotNil(fmt.Errorf("lock file '%s' is not formatted, run 'grabit fmt'", lockFile))
+		}
+		return
+	}
+	err = lock.Save()
+	FatalIfNotNil(err)
+}
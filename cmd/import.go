@@ -0,0 +1,61 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"github.com/cisco-open/grabit/internal"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importChecksumsCmd)
+	importChecksumsCmd.Flags().String("base-url", "", "Url the file names of the checksum file are relative to (default: location of the checksum file)")
+	importChecksumsCmd.Flags().Int("sample", 0, "Number of resources picked at random downloaded to spot-check the checksum file")
+	importChecksumsCmd.Flags().StringArray("tag", []string{}, "Resource tags")
+	importChecksumsCmd.Flags().StringArray("header", []string{}, "HTTP header sent when downloading the resources ('Name: value')")
+	importChecksumsCmd.Flags().String("mode", "", "Optional permissions for the downloaded files (e.g. '755')")
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import resources from other formats",
+}
+
+var importChecksumsCmd = &cobra.Command{
+	Use:     "sha256sums <file-or-url>",
+	Aliases: []string{"checksums"},
+	Short:   "Import resources from a checksum file (e.g. SHA256SUMS) without downloading them",
+	Args:    cobra.ExactArgs(1),
+	Run:     runImportChecksums,
+}
+
+func runImportChecksums(cmd *cobra.Command, args []string) {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	FatalIfNotNil(err)
+	release, err := internal.AcquireLock(lockFile)
+	FatalIfNotNil(err)
+	defer release()
+	lock, err := internal.NewLock(lockFile, true)
+	FatalIfNotNil(err)
+	baseUrl, err := cmd.Flags().GetString("base-url")
+	FatalIfNotNil(err)
+	sample, err := cmd.Flags().GetInt("sample")
+	FatalIfNotNil(err)
+	var opts internal.ResourceOptions
+	opts.Tags, err = cmd.Flags().GetStringArray("tag")
+	FatalIfNotNil(err)
+	opts.Mode, err = cmd.Flags().GetString("mode")
+	FatalIfNotNil(err)
+	headerDefs, err := cmd.Flags().GetStringArray("header")
+	FatalIfNotNil(err)
+	opts.Headers, err = internal.ParseHeaders(headerDefs)
+	FatalIfNotNil(err)
+	count, err := lock.ImportChecksums(args[0], baseUrl, opts, sample)
+	FatalIfNotNil(err)
+	err = lock.Save()
+	FatalIfNotNil(err)
+	log.Info().Int("Count", count).Msg("Imported resources")
+}
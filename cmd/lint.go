@@ -0,0 +1,48 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cisco-open/grabit/internal"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+	lintCmd.Flags().String("fail-on", "error", "Minimum severity of the issues that make the command fail (warning, error)")
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check the lock file for errors and questionable definitions",
+	Args:  cobra.NoArgs,
+	Run:   runLint,
+}
+
+func runLint(cmd *cobra.Command, args []string) {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	FatalIfNotNil(err)
+	failOnName, err := cmd.Flags().GetString("fail-on")
+	FatalIfNotNil(err)
+	failOn, err := internal.ParseSeverity(failOnName)
+	FatalIfNotNil(err)
+	lock, err := internal.NewLock(lockFile, false)
+	FatalIfNotNil(err)
+	failed := 0
+	for _, issue := range lock.Lint() {
+		fmt.Printf("%s: %s\n", lockFile, issue)
+		if issue.Severity >= failOn {
+			failed += 1
+		}
+	}
+	if failed > 0 {
+		issues := "issues"
+		if failed == 1 {
+			issues = "issue"
+		}
+		FatalIfNotNil(fmt.Errorf("%d %s found in '%s'", failed, issues, lockFile))
+	}
+}
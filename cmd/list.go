@@ -0,0 +1,42 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/cisco-open/grabit/internal"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().StringArray("tag", []string{}, "Only list the resources with the given tag")
+	listCmd.Flags().StringArray("notag", []string{}, "Only list the resources without the given tag")
+	addOutputFlag(listCmd)
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the resources of the lock file",
+	Args:  cobra.NoArgs,
+	Run:   runList,
+}
+
+func runList(cmd *cobra.Command, args []string) {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	FatalIfNotNil(err)
+	lock, err := internal.NewLock(lockFile, false)
+	FatalIfNotNil(err)
+	tags, err := cmd.Flags().GetStringArray("tag")
+	FatalIfNotNil(err)
+	notags, err := cmd.Flags().GetStringArray("notag")
+	FatalIfNotNil(err)
+	if jsonOutput(cmd) {
+		reportResults(cmd, lock.Results(tags, notags), nil)
+		return
+	}
+	err = lock.List(os.Stdout, tags, notags)
+	FatalIfNotNil(err)
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"github.com/cisco-open/grabit/internal"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(lockCmd)
+	lockCmd.AddCommand(lockKeygenCmd)
+	lockKeygenCmd.Flags().String("private-key", "grabit.key", "Path of the generated private key")
+	lockKeygenCmd.Flags().String("public-key", "grabit.pub", "Path of the generated public key")
+	lockCmd.AddCommand(lockSignCmd)
+	lockSignCmd.Flags().String("key", "", "PEM private key used to sign the lock file")
+	FatalIfNotNil(lockSignCmd.MarkFlagRequired("key"))
+	lockCmd.AddCommand(lockVerifyCmd)
+	lockVerifyCmd.Flags().StringArray("trusted-key", []string{}, "PEM public key trusted to sign the lock file")
+	FatalIfNotNil(lockVerifyCmd.MarkFlagRequired("trusted-key"))
+	lockCmd.AddCommand(lockMigrateCmd)
+	lockCmd.AddCommand(lockConvertCmd)
+	lockConvertCmd.Flags().String("format", "", "Format of the converted lock file (toml, json, yaml)")
+	FatalIfNotNil(lockConvertCmd.MarkFlagRequired("format"))
+	lockConvertCmd.Flags().StringP("output", "o", "", "Path of the converted lock file (default: the lock file path with the extension of the format)")
+}
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Manage the lock file",
+}
+
+var lockKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate a key pair to sign lock files",
+	Args:  cobra.NoArgs,
+	Run:   runLockKeygen,
+}
+
+var lockSignCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "Write a detached signature of the lock file",
+	Args:  cobra.NoArgs,
+	Run:   runLockSign,
+}
+
+var lockVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the detached signature of the lock file",
+	Args:  cobra.NoArgs,
+	Run:   runLockVerify,
+}
+
+var lockMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the lock file to the current version of the lock file format",
+	Args:  cobra.NoArgs,
+	Run:   runLockMigrate,
+}
+
+var lockConvertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Convert the lock file to another format",
+	Args:  cobra.NoArgs,
+	Run:   runLockConvert,
+}
+
+func runLockKeygen(cmd *cobra.Command, args []string) {
+	privatePath, err := cmd.Flags().GetString("private-key")
+	FatalIfNotNil(err)
+	publicPath, err := cmd.Flags().GetString("public-key")
+	FatalIfNotNil(err)
+	err = internal.GenerateSigningKey(privatePath, publicPath)
+	FatalIfNotNil(err)
+}
+
+func runLockSign(cmd *cobra.Command, args []string) {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	FatalIfNotNil(err)
+	lock, err := internal.NewLock(lockFile, false)
+	FatalIfNotNil(err)
+	key, err := cmd.Flags().GetString("key")
+	FatalIfNotNil(err)
+	err = lock.Sign(key)
+	FatalIfNotNil(err)
+	log.Info().Str("Signature", lock.SignaturePath()).Msg("Lock file signed")
+}
+
+func runLockVerify(cmd *cobra.Command, args []string) {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	FatalIfNotNil(err)
+	lock, err := internal.NewLock(lockFile, false)
+	FatalIfNotNil(err)
+	trustedKeys, err := cmd.Flags().GetStringArray("trusted-key")
+	FatalIfNotNil(err)
+	err = lock.VerifySignature(trustedKeys)
+	FatalIfNotNil(err)
+	log.Info().Msg("Lock file signature verified")
+}
+
+func runLockMigrate(cmd *cobra.Command, args []string) {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	FatalIfNotNil(err)
+	release, err := internal.AcquireLock(lockFile)
+	FatalIfNotNil(err)
+	defer release()
+	lock, err := internal.NewLock(lockFile, false)
+	FatalIfNotNil(err)
+	from, err := lock.Migrate()
+	FatalIfNotNil(err)
+	if from == internal.LockVersion {
+		log.Info().Int("Version", from).Msg("Lock file is up to date")
+		return
+	}
+	err = lock.Save()
+	FatalIfNotNil(err)
+	log.Info().Int("From", from).Int("To", internal.LockVersion).Msg("Lock file migrated")
+}
+
+func runLockConvert(cmd *cobra.Command, args []string) {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	FatalIfNotNil(err)
+	lock, err := internal.NewLock(lockFile, false)
+	FatalIfNotNil(err)
+	format, err := cmd.Flags().GetString("format")
+	FatalIfNotNil(err)
+	output, err := cmd.Flags().GetString("output")
+	FatalIfNotNil(err)
+	if output == "" {
+		output = internal.ConvertedPath(lockFile, format)
+	}
+	release, err := internal.AcquireLock(output)
+	FatalIfNotNil(err)
+	defer release()
+	err = lock.SaveAs(output, format)
+	FatalIfNotNil(err)
+	log.Info().Str("Path", output).Msg("Lock file converted")
+}
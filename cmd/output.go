@@ -0,0 +1,81 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cisco-open/grabit/internal"
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// addOutputFlag adds the flag selecting the output format of a command.
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().String("output", "text", "Output format (text, json)")
+}
+
+// jsonOutput returns true if the command must output JSON.
+func jsonOutput(cmd *cobra.Command) bool {
+	output, err := cmd.Flags().GetString("output")
+	FatalIfNotNil(err)
+	switch output {
+	case "text":
+		return false
+	case "json":
+		return true
+	}
+	FatalIfNotNil(fmt.Errorf("unknown output format '%s' (available formats: text, json)", output))
+	return false
+}
+
+// reportResults writes the report of the command to the standard output
+// when the JSON output is selected and to the report file if any, then
+// exits if the command failed.
+func reportResults(cmd *cobra.Command, results []internal.ResourceResult, err error) {
+	report := internal.NewReport(cmd.Name(), results, err)
+	if jsonOutput(cmd) {
+		FatalIfNotNil(report.Write(os.Stdout))
+	}
+	if cmd.Flags().Lookup("report") != nil {
+		reportPath, rErr := cmd.Flags().GetString("report")
+		FatalIfNotNil(rErr)
+		if reportPath != "" {
+			file, rErr := os.Create(reportPath)
+			FatalIfNotNil(rErr)
+			rErr = report.Write(file)
+			if closeErr := file.Close(); rErr == nil {
+				rErr = closeErr
+			}
+			FatalIfNotNil(rErr)
+		}
+	}
+	FatalIfNotNil(err)
+}
+
+// startProgress displays the progress of the downloads until the returned
+// function is called. The display is redrawn on the standard error when it
+// is a terminal, and logged periodically otherwise. The logs are written
+// through the display so that they do not garble it.
+func startProgress() func() {
+	var progress *internal.Progress
+	if isatty.IsTerminal(os.Stderr.Fd()) || isatty.IsCygwinTerminal(os.Stderr.Fd()) {
+		progress = internal.NewProgress(os.Stderr, true, 200*time.Millisecond)
+	} else {
+		progress = internal.NewProgress(os.Stderr, false, 10*time.Second)
+	}
+	logger := log.Logger
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: progress})
+	internal.SetProgress(progress)
+	progress.Start()
+	return func() {
+		progress.Stop()
+		internal.SetProgress(nil)
+		log.Logger = logger
+	}
+}
@@ -4,10 +4,13 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/cisco-open/grabit/internal"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -29,9 +32,92 @@ func getPwd() string {
 var GRAB_LOCK = "grabit.lock"
 
 func init() {
-	cobra.OnInitialize(initLog)
+	cobra.OnInitialize(initLog, initCredentials, initHTTPClient, initKeyring, initLockFormat, initAllowedEnv)
 	rootCmd.PersistentFlags().StringP("lock-file", "f", filepath.Join(getPwd(), GRAB_LOCK), "lockfile path (default: $PWD/grabit.lock")
+	rootCmd.PersistentFlags().String("lock-format", "", "lockfile format (toml, json, yaml) (default: detected from the lockfile extension)")
 	rootCmd.PersistentFlags().StringP("log-level", "l", "info", "log level (trace, debug, info, warn, error, fatal)")
+	rootCmd.PersistentFlags().String("credentials", internal.DefaultCredentialsPath(), "credentials file path (default: $GRABIT_CREDENTIALS or the user configuration directory)")
+	rootCmd.PersistentFlags().String("netrc", internal.DefaultNetrcPath(), "netrc file path (default: $NETRC or ~/.netrc)")
+	rootCmd.PersistentFlags().StringSlice("allow-env", []string{}, "environment variables that resource headers can reference besides the "+internal.AllowedEnvPrefix+"* ones")
+	rootCmd.PersistentFlags().String("keyring", internal.DefaultKeyringPath(), "file of public keys trusted to sign resources (default: $GRABIT_KEYRING or the user configuration directory)")
+	rootCmd.PersistentFlags().String("proxy", "", "proxy url (default: $HTTPS_PROXY/$HTTP_PROXY)")
+	rootCmd.PersistentFlags().String("cacert", "", "PEM bundle of additional trusted certificate authorities (default: $GRABIT_CACERT)")
+	rootCmd.PersistentFlags().String("client-cert", "", "PEM client certificate used for mutual TLS (default: $GRABIT_CLIENT_CERT)")
+	rootCmd.PersistentFlags().String("client-key", "", "PEM client key used for mutual TLS (default: $GRABIT_CLIENT_KEY)")
+	rootCmd.PersistentFlags().Bool("insecure-skip-verify", false, "disable TLS certificate verification (INSECURE)")
+	rootCmd.PersistentFlags().Duration("timeout", 0, "timeout of each download, 0 means no timeout (default: $GRABIT_TIMEOUT)")
+	rootCmd.PersistentFlags().Duration("connect-timeout", 30*time.Second, "timeout of connection establishment (default: $GRABIT_CONNECT_TIMEOUT or 30s)")
+}
+
+func initCredentials() {
+	path, err := rootCmd.Flags().GetString("credentials")
+	FatalIfNotNil(err)
+	netrcPath, err := rootCmd.Flags().GetString("netrc")
+	FatalIfNotNil(err)
+	credentials, err := internal.LoadCredentials(path, netrcPath)
+	FatalIfNotNil(err)
+	internal.SetCredentials(credentials)
+}
+
+func initLockFormat() {
+	format, err := rootCmd.Flags().GetString("lock-format")
+	FatalIfNotNil(err)
+	err = internal.SetLockFormat(format)
+	FatalIfNotNil(err)
+}
+
+func initAllowedEnv() {
+	names, err := rootCmd.Flags().GetStringSlice("allow-env")
+	FatalIfNotNil(err)
+	internal.SetAllowedEnv(names)
+}
+
+func initKeyring() {
+	path, err := rootCmd.Flags().GetString("keyring")
+	FatalIfNotNil(err)
+	err = internal.LoadKeyring(path)
+	FatalIfNotNil(err)
+}
+
+// httpEnv maps the HTTP client flags to the environment variables setting
+// them when they are not given on the command line.
+var httpEnv = map[string]string{
+	"cacert":          "GRABIT_CACERT",
+	"client-cert":     "GRABIT_CLIENT_CERT",
+	"client-key":      "GRABIT_CLIENT_KEY",
+	"timeout":         "GRABIT_TIMEOUT",
+	"connect-timeout": "GRABIT_CONNECT_TIMEOUT",
+}
+
+func initHTTPClient() {
+	var opts internal.HTTPOptions
+	var err error
+	for flag, env := range httpEnv {
+		value := os.Getenv(env)
+		if value == "" || rootCmd.Flags().Changed(flag) {
+			continue
+		}
+		err = rootCmd.Flags().Set(flag, value)
+		if err != nil {
+			FatalIfNotNil(fmt.Errorf("invalid $%s: %s", env, err))
+		}
+	}
+	opts.Proxy, err = rootCmd.Flags().GetString("proxy")
+	FatalIfNotNil(err)
+	opts.CACert, err = rootCmd.Flags().GetString("cacert")
+	FatalIfNotNil(err)
+	opts.ClientCert, err = rootCmd.Flags().GetString("client-cert")
+	FatalIfNotNil(err)
+	opts.ClientKey, err = rootCmd.Flags().GetString("client-key")
+	FatalIfNotNil(err)
+	opts.InsecureSkipVerify, err = rootCmd.Flags().GetBool("insecure-skip-verify")
+	FatalIfNotNil(err)
+	opts.Timeout, err = rootCmd.Flags().GetDuration("timeout")
+	FatalIfNotNil(err)
+	opts.ConnectTimeout, err = rootCmd.Flags().GetDuration("connect-timeout")
+	FatalIfNotNil(err)
+	err = internal.SetHTTPOptions(opts)
+	FatalIfNotNil(err)
 }
 
 func initLog() {
@@ -0,0 +1,49 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cisco-open/grabit/internal"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(sbomCmd)
+	sbomCmd.Flags().String("format", "cyclonedx-json", fmt.Sprintf("SBOM format (%s)", strings.Join(internal.SbomFormats(), ", ")))
+	sbomCmd.Flags().StringP("output", "o", "", "Path of the SBOM file (default: standard output)")
+}
+
+var sbomCmd = &cobra.Command{
+	Use:   "sbom",
+	Short: "Generate a software bill of materials of the resources",
+	Args:  cobra.NoArgs,
+	Run:   runSbom,
+}
+
+func runSbom(cmd *cobra.Command, args []string) {
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	FatalIfNotNil(err)
+	lock, err := internal.NewLock(lockFile, false)
+	FatalIfNotNil(err)
+	format, err := cmd.Flags().GetString("format")
+	FatalIfNotNil(err)
+	output, err := cmd.Flags().GetString("output")
+	FatalIfNotNil(err)
+	if output == "" {
+		err = lock.WriteSbom(os.Stdout, format)
+		FatalIfNotNil(err)
+		return
+	}
+	file, err := os.Create(output)
+	FatalIfNotNil(err)
+	err = lock.WriteSbom(file, format)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	FatalIfNotNil(err)
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package cmd
+
+import (
+	"github.com/cisco-open/grabit/internal"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().String("dir", ".", "Directory where the files were downloaded")
+	verifyCmd.Flags().StringArray("tag", []string{}, "Only verify the resources with the given tag")
+	verifyCmd.Flags().StringArray("notag", []string{}, "Only verify the resources without the given tag")
+	verifyCmd.Flags().String("report", "", "Write a JSON report of the verification to the given path")
+	addOutputFlag(verifyCmd)
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify previously downloaded resources",
+	Args:  cobra.NoArgs,
+	Run:   runVerify,
+}
+
+func runVerify(cmd *cobra.Command, args []string) {
+	jsonOutput(cmd)
+	lockFile, err := cmd.Flags().GetString("lock-file")
+	FatalIfNotNil(err)
+	lock, err := internal.NewLock(lockFile, false)
+	FatalIfNotNil(err)
+	dir, err := cmd.Flags().GetString("dir")
+	FatalIfNotNil(err)
+	tags, err := cmd.Flags().GetStringArray("tag")
+	FatalIfNotNil(err)
+	notags, err := cmd.Flags().GetStringArray("notag")
+	FatalIfNotNil(err)
+	results, err := lock.Verify(dir, tags, notags)
+	reportResults(cmd, results, err)
+}
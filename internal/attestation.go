@@ -0,0 +1,170 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	slsaProvenanceType  = "https://slsa.dev/provenance/v1"
+	grabitBuildType     = "https://github.com/cisco-open/grabit/download/v1"
+	grabitBuilderId     = "https://github.com/cisco-open/grabit"
+)
+
+// resourceDescriptor is an in-toto resource descriptor.
+type resourceDescriptor struct {
+	Name        string            `json:"name,omitempty"`
+	Uri         string            `json:"uri,omitempty"`
+	Digest      map[string]string `json:"digest"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type provenanceBuildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   map[string]any       `json:"externalParameters"`
+	ResolvedDependencies []resourceDescriptor `json:"resolvedDependencies"`
+}
+
+type provenanceRunDetails struct {
+	Builder struct {
+		Id      string            `json:"id"`
+		Version map[string]string `json:"version"`
+	} `json:"builder"`
+	Metadata struct {
+		StartedOn  string `json:"startedOn"`
+		FinishedOn string `json:"finishedOn"`
+	} `json:"metadata"`
+}
+
+type provenance struct {
+	BuildDefinition provenanceBuildDefinition `json:"buildDefinition"`
+	RunDetails      provenanceRunDetails      `json:"runDetails"`
+}
+
+type inTotoStatement struct {
+	Type          string               `json:"_type"`
+	Subject       []resourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     provenance           `json:"predicate"`
+}
+
+// WriteAttestation writes an in-toto statement with a SLSA provenance
+// predicate describing the downloads made to the given directory. Every
+// installed file is a subject, and the lock file, the lock files it
+// includes and every resource are resolved dependencies.
+func (l *Lock) WriteAttestation(w io.Writer, dir string, results []ResourceResult) error {
+	statement := inTotoStatement{
+		Type:          inTotoStatementType,
+		Subject:       []resourceDescriptor{},
+		PredicateType: slsaProvenanceType,
+	}
+	p := &statement.Predicate
+	p.BuildDefinition.BuildType = grabitBuildType
+	p.BuildDefinition.ExternalParameters = map[string]any{"lockFile": filepath.ToSlash(l.path)}
+	p.BuildDefinition.ResolvedDependencies = []resourceDescriptor{}
+	for _, path := range append([]string{l.path}, l.includedFiles...) {
+		digest, err := fileSha256(path)
+		if err != nil {
+			return err
+		}
+		p.BuildDefinition.ResolvedDependencies = append(p.BuildDefinition.ResolvedDependencies, resourceDescriptor{
+			Uri:    filepath.ToSlash(path),
+			Digest: map[string]string{"sha256": digest},
+		})
+	}
+	p.RunDetails.Builder.Id = grabitBuilderId
+	p.RunDetails.Builder.Version = map[string]string{"grabit": Version}
+	var started, finished time.Time
+	for _, result := range results {
+		if started.IsZero() || result.Started.Before(started) {
+			started = result.Started
+		}
+		if result.Finished.After(finished) {
+			finished = result.Finished
+		}
+		algo, digest, err := integrityDigest(result.Resource.Integrity)
+		if err != nil {
+			return err
+		}
+		p.BuildDefinition.ResolvedDependencies = append(p.BuildDefinition.ResolvedDependencies, resourceDescriptor{
+			Name:   result.Resource.name(),
+			Uri:    result.Url,
+			Digest: map[string]string{algo: digest},
+			Annotations: map[string]string{
+				"startedOn":  result.Started.UTC().Format(time.RFC3339),
+				"finishedOn": result.Finished.UTC().Format(time.RFC3339),
+			},
+		})
+		subjects, err := attestationSubjects(dir, result.Path)
+		if err != nil {
+			return err
+		}
+		statement.Subject = append(statement.Subject, subjects...)
+	}
+	sort.Slice(statement.Subject, func(i, j int) bool {
+		return statement.Subject[i].Name < statement.Subject[j].Name
+	})
+	p.RunDetails.Metadata.StartedOn = started.UTC().Format(time.RFC3339)
+	p.RunDetails.Metadata.FinishedOn = finished.UTC().Format(time.RFC3339)
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(statement)
+}
+
+// attestationSubjects returns the subjects of the files installed at the
+// given path, named relatively to the download directory.
+func attestationSubjects(dir string, path string) ([]resourceDescriptor, error) {
+	subjects := []resourceDescriptor{}
+	// Installed files may be links to the store.
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if stat.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+		digest, err := fileSha256(p)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		subjects = append(subjects, resourceDescriptor{
+			Name:   filepath.ToSlash(name),
+			Digest: map[string]string{"sha256": digest},
+		})
+		return nil
+	})
+	return subjects, err
+}
+
+// fileSha256 returns the hexadecimal SHA-256 digest of the given file.
+func fileSha256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteAttestation(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`abcdef`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	lockContent := fmt.Sprintf(`
+		[[Resource]]
+		Urls = ['http://localhost:%d/test.html']
+		Integrity = 'sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE='
+		Name = 'test'`, port)
+	path := tmpFile(t, lockContent)
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	dir := tmpDir(t)
+	results, err := lock.Download(dir, []string{}, []string{}, "", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, fmt.Sprintf("http://localhost:%d/test.html", port), results[0].Url)
+
+	var buf bytes.Buffer
+	err = lock.WriteAttestation(&buf, dir, results)
+	assert.Nil(t, err)
+	var statement inTotoStatement
+	err = json.Unmarshal(buf.Bytes(), &statement)
+	assert.Nil(t, err)
+	assert.Equal(t, inTotoStatementType, statement.Type)
+	assert.Equal(t, slsaProvenanceType, statement.PredicateType)
+	assert.Equal(t, []resourceDescriptor{{
+		Name:   "test.html",
+		Digest: map[string]string{"sha256": "bef57ec7f53a6d40beb640a780a639c83bc29ac8a9816f1fc6c5c6dcd93c4721"},
+	}}, statement.Subject)
+	deps := statement.Predicate.BuildDefinition.ResolvedDependencies
+	assert.Equal(t, 2, len(deps))
+	lockDigest, err := fileSha256(path)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"sha256": lockDigest}, deps[0].Digest)
+	assert.Equal(t, "test", deps[1].Name)
+	assert.Equal(t, results[0].Url, deps[1].Uri)
+	assert.Equal(t, map[string]string{"sha256": "bef57ec7f53a6d40beb640a780a639c83bc29ac8a9816f1fc6c5c6dcd93c4721"}, deps[1].Digest)
+	assert.NotEmpty(t, statement.Predicate.RunDetails.Metadata.StartedOn)
+}
+
+func TestWriteAttestationIncludes(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`abcdef`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	dir := tmpDir(t)
+	base := filepath.Join(dir, "base.lock")
+	writeLock(t, base, fmt.Sprintf(`
+[[Resource]]
+Urls = ['http://localhost:%d/test.html']
+Integrity = 'sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE='
+`, port))
+	common := filepath.Join(dir, "common.lock")
+	writeLock(t, common, "Include = ['base.lock']\nResource = []\n")
+	path := filepath.Join(dir, "grabit.lock")
+	writeLock(t, path, "Include = ['common.lock', 'base.lock']\nResource = []\n")
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	out := tmpDir(t)
+	results, err := lock.Download(out, []string{}, []string{}, "", nil)
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	err = lock.WriteAttestation(&buf, out, results)
+	assert.Nil(t, err)
+	var statement inTotoStatement
+	err = json.Unmarshal(buf.Bytes(), &statement)
+	assert.Nil(t, err)
+	deps := statement.Predicate.BuildDefinition.ResolvedDependencies
+	assert.Equal(t, 4, len(deps))
+	for i, p := range []string{path, common, base} {
+		digest, err := fileSha256(p)
+		assert.Nil(t, err)
+		assert.Equal(t, filepath.ToSlash(p), deps[i].Uri)
+		assert.Equal(t, map[string]string{"sha256": digest}, deps[i].Digest)
+	}
+	assert.Equal(t, results[0].Url, deps[3].Uri)
+}
+
+func TestAttestationSubjectsDirectory(t *testing.T) {
+	dir := tmpDir(t)
+	sdk := filepath.Join(dir, "sdk")
+	assert.Nil(t, os.MkdirAll(filepath.Join(sdk, "bin"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(sdk, "bin", "tool"), []byte(`abcdef`), 0755))
+	assert.Nil(t, os.Symlink("bin/tool", filepath.Join(sdk, "tool")))
+	subjects, err := attestationSubjects(dir, sdk)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(subjects))
+	assert.Equal(t, "sdk/bin/tool", subjects[0].Name)
+}
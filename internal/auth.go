@@ -0,0 +1,234 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/carlmjohnson/requests"
+	toml "github.com/pelletier/go-toml/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// Credential holds the secrets used to authenticate against a host,
+// either a bearer token or a username/password pair.
+type Credential struct {
+	Host     string
+	Username string `toml:",omitempty"`
+	Password string `toml:",omitempty"`
+	Token    string `toml:",omitempty"`
+}
+
+type credentialsConfig struct {
+	Credential []Credential
+}
+
+// Credentials resolves the credential to use for a given host. They are
+// looked up, by order of precedence, in the environment, in the grabit
+// credentials file and in the netrc file.
+type Credentials struct {
+	hosts map[string]Credential
+	netrc map[string]Credential
+}
+
+// credentials used by all the downloads.
+var credentials = &Credentials{}
+
+// SetCredentials defines the credentials used by all the downloads.
+func SetCredentials(c *Credentials) {
+	credentials = c
+}
+
+// DefaultCredentialsPath returns $GRABIT_CREDENTIALS if set and
+// grabit/credentials.toml in the user configuration directory otherwise.
+func DefaultCredentialsPath() string {
+	if path := os.Getenv("GRABIT_CREDENTIALS"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "grabit", "credentials.toml")
+}
+
+// DefaultNetrcPath returns $NETRC if set and ~/.netrc otherwise.
+func DefaultNetrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// LoadCredentials reads the given credentials and netrc files. Missing
+// files are ignored.
+func LoadCredentials(path string, netrcPath string) (*Credentials, error) {
+	c := &Credentials{hosts: map[string]Credential{}, netrc: map[string]Credential{}}
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			var conf credentialsConfig
+			err = toml.Unmarshal(content, &conf)
+			if err != nil {
+				return nil, fmt.Errorf("invalid credentials file '%s': %s", path, err)
+			}
+			for _, cred := range conf.Credential {
+				if cred.Host == "" {
+					return nil, fmt.Errorf("invalid credentials file '%s': missing host", path)
+				}
+				c.hosts[cred.Host] = cred
+			}
+		}
+	}
+	if netrcPath != "" {
+		file, err := os.Open(netrcPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			defer file.Close()
+			c.netrc, err = parseNetrc(file)
+			if err != nil {
+				return nil, fmt.Errorf("invalid netrc file '%s': %s", netrcPath, err)
+			}
+		}
+	}
+	return c, nil
+}
+
+// parseNetrc parses the content of a netrc file. The 'default' entry, if
+// any, is stored with an empty host.
+func parseNetrc(file *os.File) (map[string]Credential, error) {
+	res := map[string]Credential{}
+	scanner := bufio.NewScanner(file)
+	var tokens []string
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			// Macro definitions end with an empty line.
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields := strings.Fields(line)
+		for i, f := range fields {
+			if strings.HasPrefix(f, "#") {
+				fields = fields[:i]
+				break
+			}
+		}
+		if len(fields) > 0 && fields[0] == "macdef" {
+			inMacro = true
+			continue
+		}
+		tokens = append(tokens, fields...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	var current *Credential
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "machine", "default":
+			if current != nil {
+				res[current.Host] = *current
+			}
+			current = &Credential{}
+			if tokens[i] == "machine" {
+				if i+1 >= len(tokens) {
+					return nil, fmt.Errorf("missing machine name")
+				}
+				i++
+				current.Host = tokens[i]
+			}
+		case "login", "password", "account":
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("missing value for '%s'", tokens[i])
+			}
+			if current == nil {
+				return nil, fmt.Errorf("'%s' defined outside of a machine entry", tokens[i])
+			}
+			switch tokens[i] {
+			case "login":
+				current.Username = tokens[i+1]
+			case "password":
+				current.Password = tokens[i+1]
+			}
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected token '%s'", tokens[i])
+		}
+	}
+	if current != nil {
+		res[current.Host] = *current
+	}
+	return res, nil
+}
+
+// envHost converts a host name to the suffix of the environment variables
+// holding its credentials (e.g. 'example.com' -> 'EXAMPLE_COM').
+func envHost(host string) string {
+	return strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, host)
+}
+
+// ForUrl returns the credential to use when downloading the given url. The
+// credentials of the netrc file are only used for https urls.
+func (c *Credentials) ForUrl(u *url.URL) (Credential, bool) {
+	for _, host := range []string{u.Host, u.Hostname()} {
+		suffix := envHost(host)
+		cred := Credential{
+			Host:     host,
+			Username: os.Getenv("GRABIT_USERNAME_" + suffix),
+			Password: os.Getenv("GRABIT_PASSWORD_" + suffix),
+			Token:    os.Getenv("GRABIT_TOKEN_" + suffix),
+		}
+		if cred.Token != "" || cred.Username != "" {
+			return cred, true
+		}
+	}
+	for _, host := range []string{u.Host, u.Hostname()} {
+		if cred, ok := c.hosts[host]; ok {
+			return cred, true
+		}
+	}
+	for _, host := range []string{u.Host, u.Hostname(), ""} {
+		if cred, ok := c.netrc[host]; ok {
+			// The netrc file, and its default entry in particular, is
+			// shared with other tools: its credentials are only sent
+			// over TLS.
+			if u.Scheme != "https" {
+				log.Debug().Str("URL", u.Redacted()).Msg("Not sending netrc credentials without TLS")
+				break
+			}
+			return cred, true
+		}
+	}
+	return Credential{}, false
+}
+
+// apply adds the authentication header matching this credential.
+func (c Credential) apply(rb *requests.Builder) *requests.Builder {
+	if c.Token != "" {
+		return rb.Bearer(c.Token)
+	}
+	return rb.BasicAuth(c.Username, c.Password)
+}
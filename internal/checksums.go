@@ -0,0 +1,151 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// checksum is an entry of a checksum manifest.
+type checksum struct {
+	algo   string
+	digest string
+	name   string
+}
+
+// integrity returns the SRI matching this checksum.
+func (c checksum) integrity() (string, error) {
+	return hexToIntegrity(c.algo, c.digest)
+}
+
+// algosByHexLength maps the length of hexadecimal digests to the
+// algorithm producing them.
+var algosByHexLength = map[int]string{
+	40:  "sha1",
+	64:  "sha256",
+	96:  "sha384",
+	128: "sha512",
+}
+
+// bsdChecksum matches the lines of checksum manifests produced with
+// 'shasum --tag' (e.g. 'SHA256 (file) = <hex>').
+var bsdChecksum = regexp.MustCompile(`^(SHA1|SHA256|SHA384|SHA512) \((.+)\) = ([0-9a-fA-F]+)$`)
+
+// parseChecksums parses checksum manifests such as SHA256SUMS files, in
+// the GNU ('<hex>  file' or '<hex> *file') or BSD ('SHA256 (file) = <hex>')
+// formats.
+func parseChecksums(content string) ([]checksum, error) {
+	var res []checksum
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var c checksum
+		if m := bsdChecksum.FindStringSubmatch(line); m != nil {
+			c = checksum{algo: strings.ToLower(m[1]), name: m[2], digest: strings.ToLower(m[3])}
+		} else {
+			digest, name, found := strings.Cut(line, " ")
+			if !found {
+				return nil, fmt.Errorf("invalid checksum line %d: '%s'", n, line)
+			}
+			name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*")
+			c = checksum{algo: algosByHexLength[len(digest)], name: name, digest: strings.ToLower(digest)}
+		}
+		if _, err := hex.DecodeString(c.digest); err != nil || c.algo == "" || len(c.digest) == 0 {
+			return nil, fmt.Errorf("invalid checksum line %d: '%s'", n, line)
+		}
+		if algosByHexLength[len(c.digest)] != c.algo {
+			return nil, fmt.Errorf("invalid %s digest on line %d", c.algo, n)
+		}
+		c.name = strings.TrimPrefix(c.name, "./")
+		res = append(res, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// findChecksum returns the checksum of the given file name. Entries are
+// matched on their full name first and on their base name otherwise.
+func findChecksum(checksums []checksum, name string) (*checksum, error) {
+	var found *checksum
+	for i, c := range checksums {
+		if c.name == name {
+			return &checksums[i], nil
+		}
+		if path.Base(c.name) == name {
+			if found != nil && found.name != c.name {
+				return nil, fmt.Errorf("several checksums found for '%s'", name)
+			}
+			found = &checksums[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no checksum found for '%s'", name)
+	}
+	return found, nil
+}
+
+// hexToIntegrity converts a hexadecimal digest to an SRI.
+func hexToIntegrity(algo string, digest string) (string, error) {
+	if _, err := NewHash(algo); err != nil {
+		return "", err
+	}
+	raw, err := hex.DecodeString(digest)
+	if err != nil {
+		return "", fmt.Errorf("invalid hexadecimal digest '%s'", digest)
+	}
+	return fmt.Sprintf("%s-%s", algo, base64.StdEncoding.EncodeToString(raw)), nil
+}
+
+// getChecksums downloads and parses the checksum manifest at the given url.
+func getChecksums(u string, headers map[string]string, ctx context.Context) ([]checksum, error) {
+	p, err := GetUrltoTempFile(u, headers, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get checksums: %s", err)
+	}
+	defer os.Remove(p)
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	checksums, err := parseChecksums(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksums '%s': %s", u, err)
+	}
+	return checksums, nil
+}
+
+// verifyChecksums checks the given file against its entry in the checksum
+// manifest published at the given url.
+func (l *Resource) verifyChecksums(path string, u string, ctx context.Context) error {
+	checksums, err := getChecksums(l.ChecksumsUrl, l.Headers, ctx)
+	if err != nil {
+		return err
+	}
+	c, err := findChecksum(checksums, l.urlName(u))
+	if err != nil {
+		return fmt.Errorf("%s in '%s'", err, l.ChecksumsUrl)
+	}
+	expected, err := c.integrity()
+	if err != nil {
+		return err
+	}
+	err = checkIntegrityFromFile(path, c.algo, expected, u)
+	if err != nil {
+		return fmt.Errorf("published checksum mismatch: %s", err)
+	}
+	return nil
+}
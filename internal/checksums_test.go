@@ -0,0 +1,75 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sha256 of 'abcdef'.
+const abcdefSha256 = "bef57ec7f53a6d40beb640a780a639c83bc29ac8a9816f1fc6c5c6dcd93c4721"
+
+func TestParseChecksums(t *testing.T) {
+	checksums, err := parseChecksums(fmt.Sprintf(`
+# comment
+%s  test.html
+%s *./bin/tool
+SHA1 (other.txt) = 1f8ac10f23c5b5bc1167bda84b833e5c057a77d2
+`, abcdefSha256, strings.ToUpper(abcdefSha256)))
+	assert.Nil(t, err)
+	assert.Equal(t, []checksum{
+		{algo: "sha256", digest: abcdefSha256, name: "test.html"},
+		{algo: "sha256", digest: abcdefSha256, name: "bin/tool"},
+		{algo: "sha1", digest: "1f8ac10f23c5b5bc1167bda84b833e5c057a77d2", name: "other.txt"},
+	}, checksums)
+
+	c, err := findChecksum(checksums, "tool")
+	assert.Nil(t, err)
+	assert.Equal(t, "bin/tool", c.name)
+	integrity, err := c.integrity()
+	assert.Nil(t, err)
+	assert.Equal(t, "sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE=", integrity)
+	_, err = findChecksum(checksums, "missing")
+	assert.NotNil(t, err)
+
+	for _, invalid := range []string{"nothex  file", "abcd  file", "SHA256 (file) = abcd", "lonely"} {
+		_, err = parseChecksums(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestNewResourceFromUrlChecksums(t *testing.T) {
+	sums := fmt.Sprintf("%s  test.html\n%s  other.html\n", abcdefSha256, strings.Repeat("0", 64))
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if r.URL.Path == "/SHA256SUMS" {
+			_, err = w.Write([]byte(sums))
+		} else {
+			_, err = w.Write([]byte(`abcdef`))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	sumsUrl := fmt.Sprintf("http://localhost:%d/SHA256SUMS", port)
+
+	resource, err := NewResourceFromUrl([]string{fmt.Sprintf("http://localhost:%d/test.html", port)}, "sha512", ResourceOptions{ChecksumsUrl: sumsUrl})
+	assert.Nil(t, err)
+	assert.Equal(t, sumsUrl, resource.ChecksumsUrl)
+
+	_, err = NewResourceFromUrl([]string{fmt.Sprintf("http://localhost:%d/other.html", port)}, "sha256", ResourceOptions{ChecksumsUrl: sumsUrl})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "published checksum mismatch")
+
+	_, err = NewResourceFromUrl([]string{fmt.Sprintf("http://localhost:%d/missing.html", port)}, "sha256", ResourceOptions{ChecksumsUrl: sumsUrl})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no checksum found for 'missing.html'")
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// HTTPOptions configures the HTTP client used to download resources.
+type HTTPOptions struct {
+	// Proxy is the URL of the proxy to use. When empty, the proxy is
+	// taken from the HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment variables.
+	Proxy string
+	// CACert is the path to a PEM bundle of additional trusted CAs.
+	CACert string
+	// ClientCert and ClientKey are the paths to the PEM certificate and
+	// key used for mutual TLS authentication.
+	ClientCert string
+	ClientKey  string
+	// InsecureSkipVerify disables the verification of server certificates.
+	InsecureSkipVerify bool
+	// Timeout limits the duration of a whole request, 0 means no limit.
+	Timeout time.Duration
+	// ConnectTimeout limits the duration of connection establishment.
+	ConnectTimeout time.Duration
+}
+
+// httpClient is used by all the downloads.
+var httpClient = http.DefaultClient
+
+// httpOptions are the options of httpClient, which also configure the
+// tools run to download resources, e.g. git.
+var httpOptions HTTPOptions
+
+// SetHTTPClient defines the HTTP client used by all the downloads.
+func SetHTTPClient(c *http.Client) {
+	httpClient = c
+}
+
+// SetHTTPOptions defines the HTTP client used by all the downloads, and
+// the network configuration of the tools run to download resources, from
+// the given options.
+func SetHTTPOptions(opts HTTPOptions) error {
+	client, err := NewHTTPClient(opts)
+	if err != nil {
+		return err
+	}
+	httpClient = client
+	httpOptions = opts
+	return nil
+}
+
+// NewHTTPClient creates an HTTP client from the given options.
+func NewHTTPClient(opts HTTPOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.Proxy != "" {
+		proxy, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url '%s': %s", opts.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if opts.ConnectTimeout > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   opts.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		transport.TLSHandshakeTimeout = opts.ConnectTimeout
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.CACert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(opts.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA bundle '%s': %s", opts.CACert, err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in CA bundle '%s'", opts.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		if opts.ClientCert == "" || opts.ClientKey == "" {
+			return nil, fmt.Errorf("both a client certificate and a client key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if opts.InsecureSkipVerify {
+		log.Warn().Msg("TLS certificate verification is DISABLED, connections are vulnerable to man-in-the-middle attacks")
+		tlsConfig.InsecureSkipVerify = true
+	}
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: opts.Timeout}, nil
+}
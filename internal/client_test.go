@@ -0,0 +1,78 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func useHTTPClient(t *testing.T, opts HTTPOptions) {
+	previous, previousOpts := httpClient, httpOptions
+	err := SetHTTPOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { httpClient, httpOptions = previous, previousOpts })
+}
+
+func TestHTTPClientCACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`abcdef`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer server.Close()
+	target := filepath.Join(tmpDir(t), "test.html")
+
+	useHTTPClient(t, HTTPOptions{})
+	_, err := getUrl(server.URL, target, nil, context.Background())
+	assert.NotNil(t, err)
+
+	caCert := tmpFile(t, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})))
+	useHTTPClient(t, HTTPOptions{CACert: caCert})
+	_, err = getUrl(server.URL, target, nil, context.Background())
+	assert.Nil(t, err)
+
+	useHTTPClient(t, HTTPOptions{InsecureSkipVerify: true})
+	_, err = getUrl(server.URL, target, nil, context.Background())
+	assert.Nil(t, err)
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	proxied := ""
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		_, err := w.Write([]byte(`abcdef`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer proxy.Close()
+	useHTTPClient(t, HTTPOptions{Proxy: proxy.URL})
+	target := filepath.Join(tmpDir(t), "test.html")
+	_, err := getUrl("http://example.invalid/test.html", target, nil, context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "http://example.invalid/test.html", proxied)
+	content, err := os.ReadFile(target)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(`abcdef`), content)
+}
+
+func TestNewHTTPClientInvalid(t *testing.T) {
+	_, err := NewHTTPClient(HTTPOptions{ClientCert: "cert.pem"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "both a client certificate and a client key are required")
+	_, err = NewHTTPClient(HTTPOptions{CACert: tmpFile(t, "not a certificate")})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no certificate found")
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// resourceHeader matches the header of a resource table.
+var resourceHeader = regexp.MustCompile(`^\s*\[\[\s*Resource\s*\]\]`)
+
+// lockComments holds the comments of a lock file so that they survive
+// its rewriting.
+type lockComments struct {
+	// header holds the comments at the top of the file, separated from
+	// the first resource by a blank line.
+	header []string
+	// resources holds the comments of the resources by resource key.
+	resources map[string]*resourceComments
+	// unsupported is set when the lock file has comments that cannot be
+	// kept, i.e. in a YAML lock file.
+	unsupported bool
+}
+
+// resourceComments holds the comments preceding the header of a resource
+// and the ones in its body.
+type resourceComments struct {
+	leading []string
+	body    []string
+}
+
+// commentKey identifies a resource across changes to the lock file.
+func commentKey(r *Resource) string {
+	return strings.Join(r.Urls, "\n")
+}
+
+// parseComments extracts the comments of the given lock file content,
+// whose decoded resources are given in the order they are defined in.
+// A comment block attaches to the resource it precedes or, when it is in
+// the body of a resource, to that resource. Inline comments are not kept.
+func parseComments(content []byte, resources []Resource) lockComments {
+	c := lockComments{resources: map[string]*resourceComments{}}
+	current := -1
+	var block []string
+	blankAfter := false
+	resourceAt := func(i int) *resourceComments {
+		if i < 0 || i >= len(resources) {
+			return nil
+		}
+		key := commentKey(&resources[i])
+		if _, ok := c.resources[key]; !ok {
+			c.resources[key] = &resourceComments{}
+		}
+		return c.resources[key]
+	}
+	// detach attaches the pending block to the file header or to the
+	// body of the current resource.
+	detach := func() {
+		if len(block) == 0 {
+			return
+		}
+		if current < 0 {
+			c.header = append(c.header, block...)
+		} else if rc := resourceAt(current); rc != nil {
+			rc.body = append(rc.body, block...)
+		}
+		block = nil
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, COMMENT_PREFIX):
+			if blankAfter {
+				detach()
+			}
+			block = append(block, trimmed)
+			blankAfter = false
+		case trimmed == "":
+			blankAfter = len(block) > 0
+		case resourceHeader.MatchString(trimmed):
+			if current < 0 && blankAfter {
+				detach()
+			}
+			current++
+			if rc := resourceAt(current); rc != nil {
+				rc.leading = append(rc.leading, block...)
+			}
+			block = nil
+			blankAfter = false
+		default:
+			detach()
+			blankAfter = false
+		}
+	}
+	detach()
+	return c
+}
+
+// dropped returns true if the comments cannot be kept in a lock file in
+// the given format.
+func (c lockComments) dropped(format string) bool {
+	return c.unsupported || (format != "toml" && (len(c.header) > 0 || len(c.resources) > 0))
+}
+
+// hasYamlComments returns true if the given YAML content has comments.
+func hasYamlComments(content []byte) bool {
+	var doc yaml.Node
+	if yaml.Unmarshal(content, &doc) != nil {
+		return false
+	}
+	var walk func(n *yaml.Node) bool
+	walk = func(n *yaml.Node) bool {
+		if n.HeadComment != "" || n.LineComment != "" || n.FootComment != "" {
+			return true
+		}
+		for _, child := range n.Content {
+			if walk(child) {
+				return true
+			}
+		}
+		return false
+	}
+	return walk(&doc)
+}
+
+// apply inserts the comments in the given lock file content, whose
+// resources are given in the order they are defined in.
+func (c lockComments) apply(content []byte, resources []Resource) []byte {
+	if len(c.header) == 0 && len(c.resources) == 0 {
+		return content
+	}
+	lines := []string{}
+	if len(c.header) > 0 {
+		lines = append(lines, c.header...)
+		lines = append(lines, "")
+	}
+	current := -1
+	for _, line := range strings.Split(string(content), "\n") {
+		if !resourceHeader.MatchString(line) {
+			lines = append(lines, line)
+			continue
+		}
+		current++
+		var rc *resourceComments
+		if current < len(resources) {
+			rc = c.resources[commentKey(&resources[current])]
+		}
+		if rc == nil {
+			lines = append(lines, line)
+			continue
+		}
+		lines = append(lines, rc.leading...)
+		lines = append(lines, line)
+		lines = append(lines, rc.body...)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const commentedLock = `# Assets of the build.
+# Run 'grabit download' to fetch them.
+
+# Pinned until the 2.x migration.
+[[Resource]]
+	# Mirror of the upstream release.
+	Urls = ['http://localhost:123456/b.html']
+	Integrity = 'sha256-asdasdasd'
+
+[[Resource]]
+	Urls = ['http://localhost:123456/a.html']
+	Integrity = 'sha256-asdasdasd'
+	# Checked by hand.
+`
+
+func TestParseComments(t *testing.T) {
+	lock, err := NewLock(tmpFile(t, commentedLock), false)
+	assert.Nil(t, err)
+	c := lock.comments
+	assert.Equal(t, []string{"# Assets of the build.", "# Run 'grabit download' to fetch them."}, c.header)
+	b := c.resources["http://localhost:123456/b.html"]
+	assert.Equal(t, []string{"# Pinned until the 2.x migration."}, b.leading)
+	assert.Equal(t, []string{"# Mirror of the upstream release."}, b.body)
+	a := c.resources["http://localhost:123456/a.html"]
+	assert.Empty(t, a.leading)
+	assert.Equal(t, []string{"# Checked by hand."}, a.body)
+}
+
+func TestSaveKeepsComments(t *testing.T) {
+	path := tmpFile(t, commentedLock)
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	err = lock.Save()
+	assert.Nil(t, err)
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, `# Assets of the build.
+# Run 'grabit download' to fetch them.
+
+Version = 1
+
+[[Resource]]
+# Checked by hand.
+Urls = ['http://localhost:123456/a.html']
+Integrity = 'sha256-asdasdasd'
+
+# Pinned until the 2.x migration.
+[[Resource]]
+# Mirror of the upstream release.
+Urls = ['http://localhost:123456/b.html']
+Integrity = 'sha256-asdasdasd'
+`, string(content))
+
+	// Saving again does not change the file.
+	lock, err = NewLock(path, false)
+	assert.Nil(t, err)
+	formatted, err := lock.IsFormatted()
+	assert.Nil(t, err)
+	assert.True(t, formatted)
+}
+
+func TestDeleteResourceComments(t *testing.T) {
+	path := tmpFile(t, commentedLock)
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	err = lock.DeleteResource("http://localhost:123456/b.html")
+	assert.Nil(t, err)
+	err = lock.Save()
+	assert.Nil(t, err)
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(content), "2.x migration")
+	assert.NotContains(t, string(content), "Mirror")
+	assert.Contains(t, string(content), "# Checked by hand.")
+	assert.Contains(t, string(content), "# Assets of the build.")
+}
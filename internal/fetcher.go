@@ -0,0 +1,93 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/rs/zerolog/log"
+)
+
+// Fetcher downloads the content behind a url to a local file. The
+// downloaded content goes through the same integrity verification
+// whatever the fetcher used.
+type Fetcher interface {
+	Fetch(ctx context.Context, u *url.URL, headers map[string]string, fileName string) error
+}
+
+// fetchers maps the supported url schemes to their fetcher.
+var fetchers = map[string]Fetcher{
+	"http":  httpFetcher{},
+	"https": httpFetcher{},
+	"file":  fileFetcher{},
+	"s3":    s3Fetcher{},
+	"gs":    gsFetcher{},
+	"oci":   ociFetcher{},
+	// git+<transport> urls point to a commit of a git repository.
+	"git":       gitFetcher{},
+	"git+file":  gitFetcher{},
+	"git+http":  gitFetcher{},
+	"git+https": gitFetcher{},
+	"git+ssh":   gitFetcher{},
+}
+
+// fileNamer is implemented by the fetchers whose urls do not end with a
+// suitable file name.
+type fileNamer interface {
+	fileName(u *url.URL) (string, error)
+}
+
+// RegisterFetcher defines the fetcher used for the given url scheme.
+func RegisterFetcher(scheme string, f Fetcher) {
+	fetchers[strings.ToLower(scheme)] = f
+}
+
+func getFetcher(u *url.URL) (Fetcher, error) {
+	f, ok := fetchers[strings.ToLower(u.Scheme)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported url scheme '%s'", u.Scheme)
+	}
+	return f, nil
+}
+
+// httpFetcher downloads resources over HTTP(S).
+type httpFetcher struct{}
+
+func (httpFetcher) Fetch(ctx context.Context, u *url.URL, headers map[string]string, fileName string) error {
+	rb := requests.
+		URL(u.String()).
+		Client(httpClient).
+		Header("Accept", "*/*")
+	if cred, ok := credentials.ForUrl(u); ok {
+		log.Debug().Str("URL", u.String()).Msg("Using credentials")
+		rb = cred.apply(rb)
+	}
+	expandedHeaders, err := expandHeaders(headers)
+	if err != nil {
+		return err
+	}
+	for k, v := range expandedHeaders {
+		rb = rb.Header(k, v)
+	}
+	return rb.
+		Handle(progressHandler(fileName)).
+		Fetch(ctx)
+}
+
+// fileFetcher copies resources from the local file system.
+type fileFetcher struct{}
+
+func (fileFetcher) Fetch(ctx context.Context, u *url.URL, headers map[string]string, fileName string) error {
+	if u.Host != "" && u.Host != "localhost" {
+		return fmt.Errorf("remote file host '%s' is not supported", u.Host)
+	}
+	if u.Path == "" {
+		return fmt.Errorf("missing file path")
+	}
+	return copyFile(u.Path, fileName)
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type staticFetcher struct {
+	content string
+}
+
+func (f staticFetcher) Fetch(ctx context.Context, u *url.URL, headers map[string]string, fileName string) error {
+	return os.WriteFile(fileName, []byte(f.content), 0644)
+}
+
+func TestFileFetcher(t *testing.T) {
+	src := tmpFile(t, "abcdef")
+	resource, err := NewResourceFromUrl([]string{"file://" + src}, "sha256", ResourceOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE=", resource.Integrity)
+
+	dir := tmpDir(t)
+	_, err = resource.Download(dir, NoFileMode, nil, context.Background())
+	assert.Nil(t, err)
+	content, err := os.ReadFile(filepath.Join(dir, filepath.Base(src)))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("abcdef"), content)
+
+	_, err = NewResourceFromUrl([]string{"file://remote/path"}, "sha256", ResourceOptions{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "remote file host")
+}
+
+func TestUnsupportedScheme(t *testing.T) {
+	_, err := NewResourceFromUrl([]string{"ftp://example.com/test"}, "sha256", ResourceOptions{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unsupported url scheme 'ftp'")
+}
+
+func TestRegisterFetcher(t *testing.T) {
+	RegisterFetcher("test", staticFetcher{content: "abcdef"})
+	defer delete(fetchers, "test")
+	resource, err := NewResourceFromUrl([]string{"test://anything/test.html"}, "sha256", ResourceOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE=", resource.Integrity)
+}
@@ -0,0 +1,17 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+//go:build !unix
+
+package internal
+
+// lockDir is only implemented on unix systems, concurrent modifications
+// are still detected when saving.
+func lockDir(dir string) (func(), error) {
+	return func() {}, nil
+}
+
+// syncDir is only implemented on unix systems.
+func syncDir(dir string) error {
+	return nil
+}
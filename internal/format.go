@@ -0,0 +1,205 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	toml "github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// lockCodec reads and writes lock files in a given format.
+type lockCodec struct {
+	// decode decodes the content of a lock file. When strict is set,
+	// the fields that are not part of the configuration are rejected.
+	decode func(content []byte, conf *config, strict bool) error
+	encode func(conf config) ([]byte, error)
+}
+
+// lockFormats maps the supported lock file formats to their codec.
+var lockFormats = map[string]lockCodec{
+	"toml": {decodeToml, encodeToml},
+	"json": {decodeJson, encodeJson},
+	"yaml": {decodeYaml, encodeYaml},
+}
+
+// lockFormat is the format of the lock files, detected from their
+// extension when empty.
+var lockFormat = ""
+
+// SetLockFormat forces the format of the lock files.
+func SetLockFormat(format string) error {
+	if _, ok := lockFormats[format]; format != "" && !ok {
+		return unknownFormatError(format)
+	}
+	lockFormat = format
+	return nil
+}
+
+func unknownFormatError(format string) error {
+	formats := make([]string, 0, len(lockFormats))
+	for f := range lockFormats {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return fmt.Errorf("unknown lock file format '%s' (available formats: %s)", format, strings.Join(formats, ", "))
+}
+
+// formatOf returns the format of the given lock file.
+func formatOf(path string) string {
+	if lockFormat != "" {
+		return lockFormat
+	}
+	return formatFromExt(path)
+}
+
+// formatFromExt returns the lock file format matching the extension of
+// the given path.
+func formatFromExt(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return "toml"
+}
+
+// ConvertedPath returns the default path of the conversion of the given
+// lock file to the given format.
+func ConvertedPath(path string, format string) string {
+	if formatFromExt(path) != "toml" {
+		path = strings.TrimSuffix(path, filepath.Ext(path))
+	}
+	if format == "toml" {
+		return path
+	}
+	return path + "." + format
+}
+
+// SaveAs saves the resources of this lock file to a new lock file in the
+// given format. The relative include paths are rebased on the directory
+// of the new lock file.
+func (l *Lock) SaveAs(path string, format string) error {
+	if _, ok := lockFormats[format]; !ok {
+		return unknownFormatError(format)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("'%s' already exists", path)
+	}
+	conf := l.conf
+	conf.Include = nil
+	for _, include := range l.conf.Include {
+		rebased, err := rebaseInclude(include, filepath.Dir(l.path), filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		conf.Include = append(conf.Include, rebased)
+	}
+	converted := &Lock{path: path, format: format, conf: conf, comments: l.comments}
+	return converted.Save()
+}
+
+// rebaseInclude returns the include path, relative to the from directory,
+// relative to the to directory instead.
+func rebaseInclude(include string, from string, to string) (string, error) {
+	if filepath.IsAbs(include) {
+		return include, nil
+	}
+	target, err := filepath.Abs(filepath.Join(from, include))
+	if err != nil {
+		return "", err
+	}
+	base, err := filepath.Abs(to)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		return target, nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func decodeToml(content []byte, conf *config, strict bool) error {
+	d := toml.NewDecoder(bytes.NewReader(content))
+	if strict {
+		d.DisallowUnknownFields()
+	}
+	err := d.Decode(conf)
+	var strictErr *toml.StrictMissingError
+	if errors.As(err, &strictErr) {
+		fields := []string{}
+		for _, e := range strictErr.Errors {
+			fields = append(fields, strings.Join(e.Key(), "."))
+		}
+		return fmt.Errorf("unknown fields %s", strings.Join(fields, ", "))
+	}
+	return err
+}
+
+func encodeToml(conf config) ([]byte, error) {
+	return toml.Marshal(conf)
+}
+
+func decodeJson(content []byte, conf *config, strict bool) error {
+	d := json.NewDecoder(bytes.NewReader(content))
+	if strict {
+		d.DisallowUnknownFields()
+	}
+	err := d.Decode(conf)
+	if err != nil {
+		return err
+	}
+	if _, err := d.Token(); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("unexpected data after the lock file content")
+	}
+	return nil
+}
+
+func encodeJson(conf config) ([]byte, error) {
+	res, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(res, '\n'), nil
+}
+
+func decodeYaml(content []byte, conf *config, strict bool) error {
+	d := yaml.NewDecoder(bytes.NewReader(content))
+	d.KnownFields(strict)
+	err := d.Decode(conf)
+	if errors.Is(err, io.EOF) {
+		// An empty YAML document is an empty lock file.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := d.Decode(&yaml.Node{}); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("unexpected data after the lock file content")
+	}
+	return nil
+}
+
+func encodeYaml(conf config) ([]byte, error) {
+	var buf bytes.Buffer
+	e := yaml.NewEncoder(&buf)
+	e.SetIndent(2)
+	err := e.Encode(conf)
+	if err != nil {
+		return nil, err
+	}
+	err = e.Close()
+	return buf.Bytes(), err
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatOf(t *testing.T) {
+	assert.Equal(t, "toml", formatOf("grabit.lock"))
+	assert.Equal(t, "json", formatOf("grabit.lock.json"))
+	assert.Equal(t, "yaml", formatOf("grabit.lock.yaml"))
+	assert.Equal(t, "yaml", formatOf("grabit.YML"))
+	err := SetLockFormat("json")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = SetLockFormat("") })
+	assert.Equal(t, "json", formatOf("grabit.lock"))
+	err = SetLockFormat("xml")
+	assert.ErrorContains(t, err, "unknown lock file format 'xml'")
+}
+
+func TestConvertedPath(t *testing.T) {
+	assert.Equal(t, "grabit.lock.json", ConvertedPath("grabit.lock", "json"))
+	assert.Equal(t, "grabit.lock.yaml", ConvertedPath("grabit.lock.json", "yaml"))
+	assert.Equal(t, "grabit.lock", ConvertedPath("grabit.lock.yaml", "toml"))
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	dir := tmpDir(t)
+	path := filepath.Join(dir, "grabit.lock")
+	err := os.WriteFile(path, []byte(`
+# Comment.
+[[Resource]]
+Urls = ['http://localhost:123456/a.html']
+Integrity = 'sha256-asdasdasd'
+Tags = ['tag1']
+Headers = { X-Token = '${TOKEN}' }
+`), 0644)
+	assert.Nil(t, err)
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	for _, format := range []string{"json", "yaml"} {
+		converted := ConvertedPath(path, format)
+		err = lock.SaveAs(converted, format)
+		assert.Nil(t, err)
+		content, err := os.ReadFile(converted)
+		assert.Nil(t, err)
+		assert.NotContains(t, string(content), "# Comment.")
+		loaded, err := NewLock(converted, false)
+		assert.Nil(t, err)
+		assert.Equal(t, format, loaded.format)
+		lock.conf.Version = LockVersion
+		assert.Equal(t, lock.conf, loaded.conf)
+		err = lock.SaveAs(converted, format)
+		assert.ErrorContains(t, err, "already exists")
+	}
+}
+
+func TestNewLockUnknownFieldFormats(t *testing.T) {
+	dir := tmpDir(t)
+	for name, content := range map[string]string{
+		"grabit.lock.json": `{"Resource": [{"Urls": ["http://localhost/a"], "Integrity": "sha256-asdasdasd", "Size": 12}]}`,
+		"grabit.lock.yaml": "Resource:\n  - Urls: [http://localhost/a]\n    Integrity: sha256-asdasdasd\n    Size: 12\n",
+	} {
+		path := filepath.Join(dir, name)
+		err := os.WriteFile(path, []byte(content), 0644)
+		assert.Nil(t, err)
+		_, err = NewLock(path, false)
+		assert.ErrorContains(t, err, "Size")
+		assert.ErrorContains(t, err, "upgrade grabit")
+	}
+	path := filepath.Join(dir, "newer.json")
+	err := os.WriteFile(path, []byte(`{"Version": 99, "Includes": []}`), 0644)
+	assert.Nil(t, err)
+	_, err = NewLock(path, false)
+	assert.ErrorContains(t, err, "uses version 99")
+}
+
+func TestNewLockJsonTrailingData(t *testing.T) {
+	dir := tmpDir(t)
+	for _, content := range []string{
+		`{"Resource": []} {"Resource": []}`,
+		`{"Resource": []}}`,
+	} {
+		path := filepath.Join(dir, "grabit.lock.json")
+		err := os.WriteFile(path, []byte(content), 0644)
+		assert.Nil(t, err)
+		_, err = NewLock(path, false)
+		assert.ErrorContains(t, err, "invalid lock file")
+	}
+}
+
+func TestNewLockYamlTrailingData(t *testing.T) {
+	path := filepath.Join(tmpDir(t), "grabit.lock.yaml")
+	err := os.WriteFile(path, []byte("Resource: []\n---\nResource:\n  - Urls: [http://localhost/a]\n"), 0644)
+	assert.Nil(t, err)
+	_, err = NewLock(path, false)
+	assert.ErrorContains(t, err, "unexpected data after the lock file content")
+}
+
+func TestYamlCommentsDropped(t *testing.T) {
+	path := filepath.Join(tmpDir(t), "grabit.lock.yaml")
+	err := os.WriteFile(path, []byte("# Pinned for the release.\nResource:\n  - Urls: [http://localhost/a]\n    Integrity: sha256-YWJj\n"), 0644)
+	assert.Nil(t, err)
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	assert.True(t, lock.comments.dropped(lock.format))
+
+	err = os.WriteFile(path, []byte("Resource:\n  - Urls: [http://localhost/a]\n    Integrity: sha256-YWJj\n"), 0644)
+	assert.Nil(t, err)
+	lock, err = NewLock(path, false)
+	assert.Nil(t, err)
+	assert.False(t, lock.comments.dropped(lock.format))
+
+	toml, err := NewLock(tmpFile(t, "# Pinned for the release.\n[[Resource]]\nUrls = ['http://localhost/a']\nIntegrity = 'sha256-YWJj'\n"), false)
+	assert.Nil(t, err)
+	assert.False(t, toml.comments.dropped("toml"))
+	assert.True(t, toml.comments.dropped("json"))
+}
+
+func TestConvertRebasesIncludes(t *testing.T) {
+	dir := tmpDir(t)
+	writeLock(t, filepath.Join(dir, "common", "grabit.lock"), `
+[[Resource]]
+Urls = ['http://localhost:123456/common.html']
+Integrity = 'sha256-YWJj'
+`)
+	path := filepath.Join(dir, "app", "grabit.lock")
+	writeLock(t, path, "Include = ['../common/grabit.lock']\nResource = []\n")
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	converted := filepath.Join(dir, "out", "nested", "grabit.lock.json")
+	err = os.MkdirAll(filepath.Dir(converted), 0755)
+	assert.Nil(t, err)
+	err = lock.SaveAs(converted, "json")
+	assert.Nil(t, err)
+	other, err := NewLock(converted, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"../../common/grabit.lock"}, other.conf.Include)
+	assert.True(t, other.Contains("http://localhost:123456/common.html"))
+	assert.Equal(t, []string{"../common/grabit.lock"}, lock.conf.Include)
+}
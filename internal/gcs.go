@@ -0,0 +1,40 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// gsFetcher downloads gs://bucket/object resources from Google Cloud
+// Storage through its XML API. The GOOGLE_OAUTH_ACCESS_TOKEN environment
+// variable, or the credentials matching storage.googleapis.com, are used
+// to authenticate. STORAGE_EMULATOR_HOST redirects the requests to a
+// local emulator.
+type gsFetcher struct{}
+
+func (gsFetcher) Fetch(ctx context.Context, u *url.URL, headers map[string]string, fileName string) error {
+	bucket := u.Host
+	object := strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || object == "" {
+		return fmt.Errorf("invalid GCS url '%s' (expected gs://bucket/object)", u)
+	}
+	endpoint := &url.URL{Scheme: "https", Host: "storage.googleapis.com"}
+	if emulator := os.Getenv("STORAGE_EMULATOR_HOST"); emulator != "" {
+		endpoint = &url.URL{Scheme: "http", Host: strings.TrimPrefix(emulator, "http://")}
+	}
+	endpoint.Path = "/" + bucket + "/" + object
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		withAuth := map[string]string{"Authorization": "Bearer " + token}
+		for k, v := range headers {
+			withAuth[k] = v
+		}
+		headers = withAuth
+	}
+	return httpFetcher{}.Fetch(ctx, endpoint, headers, fileName)
+}
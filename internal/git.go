@@ -0,0 +1,275 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// gitCommit matches full SHA-1 and SHA-256 commit ids.
+var gitCommit = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// gitFetcher downloads a snapshot of a git repository at a pinned commit,
+// e.g. git+https://github.com/org/repo.git#<commit sha>. The snapshot is
+// a tarball built from the git objects themselves, with sorted entries and
+// normalized metadata, so that its integrity only depends on the content
+// of the commit and not on the git version or the hosting service.
+type gitFetcher struct{}
+
+type gitEntry struct {
+	mode string
+	oid  string
+	path string
+}
+
+// parseGitUrl returns the remote and the commit of a git resource url.
+func parseGitUrl(u *url.URL) (string, string, error) {
+	commit := u.Fragment
+	if !gitCommit.MatchString(commit) {
+		return "", "", fmt.Errorf("git url '%s' must be pinned to a full commit sha (e.g. '#%s')", u, strings.Repeat("0", 40))
+	}
+	remote := *u
+	remote.Fragment = ""
+	remote.Scheme = strings.TrimPrefix(remote.Scheme, "git+")
+	return remote.String(), commit, nil
+}
+
+func (gitFetcher) fileName(u *url.URL) (string, error) {
+	name := strings.TrimSuffix(path.Base(u.Path), ".git")
+	commit := u.Fragment
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	return fmt.Sprintf("%s-%s.tar", name, commit), nil
+}
+
+func (gitFetcher) Fetch(ctx context.Context, u *url.URL, headers map[string]string, fileName string) error {
+	remote, commit, err := parseGitUrl(u)
+	if err != nil {
+		return err
+	}
+	repo, err := os.MkdirTemp("", "grabit-git")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(repo)
+	_, err = runGit(ctx, repo, nil, "init", "--quiet", "--bare")
+	if err != nil {
+		return err
+	}
+	env, err := gitEnv(headers)
+	if err != nil {
+		return err
+	}
+	if httpOptions.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, httpOptions.Timeout)
+		defer cancel()
+	}
+	log.Debug().Str("Remote", remote).Str("Commit", commit).Msg("Fetching git commit")
+	_, err = runGit(ctx, repo, env, "fetch", "--quiet", "--no-tags", "--depth", "1", remote, commit)
+	if err != nil {
+		// Not all servers allow fetching a commit by its sha.
+		log.Debug().Str("Remote", remote).Msg("Shallow fetch failed, fetching the whole repository")
+		_, err = runGit(ctx, repo, env, "fetch", "--quiet", "--no-tags", remote, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+		if err != nil {
+			return err
+		}
+	}
+	_, err = runGit(ctx, repo, nil, "cat-file", "-e", commit+"^{commit}")
+	if err != nil {
+		return fmt.Errorf("commit '%s' not found in '%s'", commit, remote)
+	}
+	out, err := runGit(ctx, repo, nil, "ls-tree", "-r", "-z", "--full-tree", commit)
+	if err != nil {
+		return err
+	}
+	var entries []gitEntry
+	for _, line := range strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00") {
+		if line == "" {
+			continue
+		}
+		meta, p, found := strings.Cut(line, "\t")
+		fields := strings.Fields(meta)
+		if !found || len(fields) != 3 {
+			return fmt.Errorf("unexpected git ls-tree output '%s'", line)
+		}
+		// Submodules are not part of the snapshot.
+		if fields[1] != "blob" {
+			continue
+		}
+		entries = append(entries, gitEntry{mode: fields[0], oid: fields[2], path: p})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+	return writeGitSnapshot(ctx, repo, entries, fileName)
+}
+
+// writeGitSnapshot writes the given blobs to a normalized tarball.
+func writeGitSnapshot(ctx context.Context, repo string, entries []gitEntry, fileName string) error {
+	var oids bytes.Buffer
+	for _, e := range entries {
+		oids.WriteString(e.oid + "\n")
+	}
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "--batch")
+	cmd.Dir = repo
+	cmd.Stdin = &oids
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("cannot run git: %s", err)
+	}
+	defer func() {
+		// Stop git if the snapshot could not be written entirely.
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	tw := tar.NewWriter(file)
+	reader := bufio.NewReader(stdout)
+	for _, e := range entries {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read git object '%s': %s", e.oid, err)
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 || fields[0] != e.oid {
+			return fmt.Errorf("unexpected git cat-file output '%s'", strings.TrimSpace(header))
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Name:    e.path,
+			ModTime: time.Unix(0, 0),
+		}
+		var content io.Reader = io.LimitReader(reader, size)
+		switch e.mode {
+		case "120000":
+			target, err := io.ReadAll(content)
+			if err != nil {
+				return err
+			}
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = string(target)
+			hdr.Mode = 0777
+			content = nil
+		case "100755":
+			hdr.Typeflag = tar.TypeReg
+			hdr.Mode = 0755
+			hdr.Size = size
+		default:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Mode = 0644
+			hdr.Size = size
+		}
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if content != nil {
+			_, err = io.Copy(tw, content)
+			if err != nil {
+				return err
+			}
+		}
+		// Each object is followed by a newline.
+		_, err = reader.Discard(1)
+		if err != nil {
+			return err
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// gitEnv returns the environment configuring the git commands fetching
+// from a remote with the network options of grabit and the given resource
+// headers. The configuration is passed in the environment rather than on
+// the command line so that the headers, which may hold secrets, do not
+// show in the process list.
+func gitEnv(headers map[string]string) ([]string, error) {
+	expandedHeaders, err := expandHeaders(headers)
+	if err != nil {
+		return nil, err
+	}
+	var config [][2]string
+	if httpOptions.Proxy != "" {
+		config = append(config, [2]string{"http.proxy", httpOptions.Proxy})
+	}
+	// git runs in a temporary directory, the paths must be absolute.
+	for _, c := range [][2]string{
+		// git only trusts the given bundle, not the system one.
+		{"http.sslCAInfo", httpOptions.CACert},
+		{"http.sslCert", httpOptions.ClientCert},
+		{"http.sslKey", httpOptions.ClientKey},
+	} {
+		if c[1] == "" {
+			continue
+		}
+		abs, err := filepath.Abs(c[1])
+		if err != nil {
+			return nil, err
+		}
+		config = append(config, [2]string{c[0], abs})
+	}
+	if httpOptions.InsecureSkipVerify {
+		config = append(config, [2]string{"http.sslVerify", "false"})
+	}
+	names := make([]string, 0, len(expandedHeaders))
+	for name := range expandedHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		config = append(config, [2]string{"http.extraHeader", name + ": " + expandedHeaders[name]})
+	}
+	env := []string{fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(config))}
+	for i, c := range config {
+		env = append(env, fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, c[0]), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, c[1]))
+	}
+	return env, nil
+}
+
+// runGit runs git in the given directory with the given additional
+// environment.
+func runGit(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %s %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gitRepo creates a repository with a single commit and returns its path
+// and the commit sha.
+func gitRepo(t *testing.T) (string, string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	dir := tmpDir(t)
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %s %s", args[0], err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "--quiet")
+	err := os.MkdirAll(filepath.Join(dir, "bin"), 0755)
+	assert.Nil(t, err)
+	err = os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme"), 0644)
+	assert.Nil(t, err)
+	err = os.WriteFile(filepath.Join(dir, "bin", "tool"), []byte("#!/bin/sh"), 0755)
+	assert.Nil(t, err)
+	err = os.Symlink("bin/tool", filepath.Join(dir, "tool"))
+	assert.Nil(t, err)
+	git("add", ".")
+	git("commit", "--quiet", "-m", "initial")
+	return dir, git("rev-parse", "HEAD")
+}
+
+func TestGitFetcher(t *testing.T) {
+	repo, commit := gitRepo(t)
+	u := "git+file://" + repo + "#" + commit
+	resource, err := NewResourceFromUrl([]string{u}, "sha256", ResourceOptions{})
+	assert.Nil(t, err)
+
+	// Downloading builds the snapshot again and checks it against the
+	// integrity computed when adding the resource.
+	dir := tmpDir(t)
+	_, err = resource.Download(dir, NoFileMode, nil, context.Background())
+	assert.Nil(t, err)
+	snapshot := filepath.Join(dir, filepath.Base(repo)+"-"+commit[:12]+".tar")
+	assert.FileExists(t, snapshot)
+
+	file, err := os.Open(snapshot)
+	assert.Nil(t, err)
+	defer file.Close()
+	tr := tar.NewReader(file)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		names = append(names, hdr.Name)
+		assert.Equal(t, int64(0), hdr.ModTime.Unix())
+		switch hdr.Name {
+		case "bin/tool":
+			assert.Equal(t, int64(0755), hdr.Mode)
+		case "tool":
+			assert.Equal(t, byte(tar.TypeSymlink), hdr.Typeflag)
+			assert.Equal(t, "bin/tool", hdr.Linkname)
+		}
+	}
+	assert.Equal(t, []string{"README.md", "bin/tool", "tool"}, names)
+}
+
+func TestGitFetcherUnpinned(t *testing.T) {
+	_, err := NewResourceFromUrl([]string{"git+https://github.com/cisco-open/grabit.git#main"}, "sha256", ResourceOptions{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "must be pinned to a full commit sha")
+}
+
+func TestGitFetcherHTTPOptions(t *testing.T) {
+	repo, commit := gitRepo(t)
+	out, err := exec.Command("git", "--exec-path").Output()
+	if err != nil {
+		t.Skip("git is not available")
+	}
+	backend := filepath.Join(strings.TrimSpace(string(out)), "git-http-backend")
+	if _, err := os.Stat(backend); err != nil {
+		t.Skip("git-http-backend is not available")
+	}
+	cgiHandler := &cgi.Handler{
+		Path: backend,
+		Env:  []string{"GIT_PROJECT_ROOT=" + filepath.Dir(repo), "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	// The server is only reachable as the proxy of the git remote.
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		cgiHandler.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+	useHTTPClient(t, HTTPOptions{Proxy: proxy.URL})
+	t.Setenv("GRABIT_HEADER_TEST_GIT_TOKEN", "secret")
+
+	u := "git+http://git.invalid/" + filepath.Base(repo) + "#" + commit
+	resource, err := NewResourceFromUrl([]string{u}, "sha256", ResourceOptions{Headers: map[string]string{"X-Token": "${GRABIT_HEADER_TEST_GIT_TOKEN}"}})
+	assert.Nil(t, err)
+	_, err = NewResourceFromUrl([]string{u}, "sha256", ResourceOptions{})
+	assert.NotNil(t, err)
+	local, err := NewResourceFromUrl([]string{"git+file://" + repo + "#" + commit}, "sha256", ResourceOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, local.Integrity, resource.Integrity)
+}
+
+func TestGitEnv(t *testing.T) {
+	useHTTPClient(t, HTTPOptions{Proxy: "http://proxy.example.com:3128", InsecureSkipVerify: true})
+	env, err := gitEnv(map[string]string{"X-B": "b", "X-A": "a"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"GIT_CONFIG_COUNT=4",
+		"GIT_CONFIG_KEY_0=http.proxy", "GIT_CONFIG_VALUE_0=http://proxy.example.com:3128",
+		"GIT_CONFIG_KEY_1=http.sslVerify", "GIT_CONFIG_VALUE_1=false",
+		"GIT_CONFIG_KEY_2=http.extraHeader", "GIT_CONFIG_VALUE_2=X-A: a",
+		"GIT_CONFIG_KEY_3=http.extraHeader", "GIT_CONFIG_VALUE_3=X-B: b",
+	}, env)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"fmt"
+	"net/textproto"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envReference matches the ${NAME} environment variable references
+// allowed in header values.
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// AllowedEnvPrefix is the prefix of the environment variables that header
+// values can reference without being explicitly allowed. Lock files are
+// not trusted to send arbitrary environment variables, e.g. cloud
+// credentials, to the servers they download from.
+const AllowedEnvPrefix = "GRABIT_HEADER_"
+
+// allowedEnv holds the environment variables header values can reference
+// besides the ones starting with AllowedEnvPrefix.
+var allowedEnv = map[string]bool{}
+
+// SetAllowedEnv allows header values to reference the given environment
+// variables.
+func SetAllowedEnv(names []string) {
+	allowedEnv = map[string]bool{}
+	for _, name := range names {
+		allowedEnv[name] = true
+	}
+}
+
+// ParseHeaders converts a list of 'Name: value' definitions to a map of
+// headers. Values are kept as is so that ${NAME} references to
+// environment variables are only resolved when downloading.
+func ParseHeaders(definitions []string) (map[string]string, error) {
+	if len(definitions) == 0 {
+		return nil, nil
+	}
+	headers := map[string]string{}
+	for _, d := range definitions {
+		name, value, found := strings.Cut(d, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header '%s' (expected 'Name: value')", d)
+		}
+		headers[textproto.CanonicalMIMEHeaderKey(name)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// expandHeaders resolves the environment variable references in the
+// given header values, which are limited to the allowed variables.
+func expandHeaders(headers map[string]string) (map[string]string, error) {
+	expanded := make(map[string]string, len(headers))
+	for name, value := range headers {
+		var missing, denied []string
+		expanded[name] = envReference.ReplaceAllStringFunc(value, func(ref string) string {
+			env := envReference.FindStringSubmatch(ref)[1]
+			if !strings.HasPrefix(env, AllowedEnvPrefix) && !allowedEnv[env] {
+				denied = append(denied, env)
+				return ""
+			}
+			v, ok := os.LookupEnv(env)
+			if !ok {
+				missing = append(missing, env)
+			}
+			return v
+		})
+		if len(denied) > 0 {
+			return nil, fmt.Errorf("environment variable '%s' used by header '%s' is not allowed, only the %s* variables and the explicitly allowed ones can be used", denied[0], name, AllowedEnvPrefix)
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("environment variable '%s' used by header '%s' is not set", missing[0], name)
+		}
+	}
+	return expanded, nil
+}
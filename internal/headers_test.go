@@ -0,0 +1,71 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders([]string{"accept: application/octet-stream", "X-Token: ${TOKEN}"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"Accept": "application/octet-stream", "X-Token": "${TOKEN}"}, headers)
+
+	headers, err = ParseHeaders([]string{})
+	assert.Nil(t, err)
+	assert.Nil(t, headers)
+
+	_, err = ParseHeaders([]string{"invalid"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "invalid header")
+}
+
+func TestExpandHeaders(t *testing.T) {
+	t.Setenv("GRABIT_HEADER_TEST_TOKEN", "secret")
+	headers, err := expandHeaders(map[string]string{"Authorization": "token ${GRABIT_HEADER_TEST_TOKEN}", "X-Price": "$5"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"Authorization": "token secret", "X-Price": "$5"}, headers)
+
+	_, err = expandHeaders(map[string]string{"X-Token": "${GRABIT_HEADER_TEST_UNDEFINED}"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "'GRABIT_HEADER_TEST_UNDEFINED' used by header 'X-Token' is not set")
+}
+
+func TestExpandHeadersAllowedEnv(t *testing.T) {
+	t.Setenv("GRABIT_TEST_SECRET", "secret")
+	_, err := expandHeaders(map[string]string{"X-Token": "${GRABIT_TEST_SECRET}"})
+	assert.ErrorContains(t, err, "'GRABIT_TEST_SECRET' used by header 'X-Token' is not allowed")
+
+	SetAllowedEnv([]string{"GRABIT_TEST_SECRET"})
+	t.Cleanup(func() { SetAllowedEnv(nil) })
+	headers, err := expandHeaders(map[string]string{"X-Token": "${GRABIT_TEST_SECRET}"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"X-Token": "secret"}, headers)
+}
+
+func TestGetUrlWithHeaders(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/octet-stream" || r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, err := w.Write([]byte(`abcdef`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	t.Setenv("GRABIT_HEADER_TEST_TOKEN", "secret")
+	u := fmt.Sprintf("http://localhost:%d/test.html", port)
+	headers := map[string]string{"Accept": "application/octet-stream", "X-Token": "${GRABIT_HEADER_TEST_TOKEN}"}
+	_, err := getUrl(u, filepath.Join(tmpDir(t), "test.html"), headers, context.Background())
+	assert.Nil(t, err)
+}
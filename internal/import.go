@@ -0,0 +1,137 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ImportChecksums adds a resource for each entry of a checksum manifest
+// (a local file or a url) without downloading them. The resource urls are
+// the entry names resolved against baseUrl, which defaults to the location
+// of the manifest when it is a url. sample resources picked at random are
+// downloaded to spot-check the manifest. Resources already present, or
+// listed several times with the same digest, are skipped. It returns the
+// number of imported resources.
+func (l *Lock) ImportChecksums(source string, baseUrl string, opts ResourceOptions, sample int) (int, error) {
+	if sample < 0 {
+		return 0, fmt.Errorf("invalid sample size %d", sample)
+	}
+	ctx := context.Background()
+	var checksums []checksum
+	var err error
+	parsedSource, parseErr := url.Parse(source)
+	isUrl := parseErr == nil && len(parsedSource.Scheme) > 1
+	if isUrl {
+		checksums, err = getChecksums(source, opts.Headers, ctx)
+		if err != nil {
+			return 0, err
+		}
+		if baseUrl == "" {
+			base := *parsedSource
+			base.Path = path.Dir(base.Path)
+			baseUrl = base.String()
+		}
+	} else {
+		content, err := os.ReadFile(source)
+		if err != nil {
+			return 0, err
+		}
+		checksums, err = parseChecksums(string(content))
+		if err != nil {
+			return 0, fmt.Errorf("invalid checksums '%s': %s", source, err)
+		}
+	}
+	if baseUrl == "" {
+		return 0, fmt.Errorf("a base url is required to import local checksum files")
+	}
+	mode, err := normalizeMode(opts.Mode)
+	if err != nil {
+		return 0, err
+	}
+
+	var resources []Resource
+	// imported maps the urls of the resources imported so far to their
+	// integrity.
+	imported := map[string]string{}
+	for _, c := range checksums {
+		u, err := joinUrl(baseUrl, c.name)
+		if err != nil {
+			return 0, err
+		}
+		if l.Contains(u) {
+			log.Warn().Str("URL", u).Msg("Resource already present, skipping")
+			continue
+		}
+		integrity, err := c.integrity()
+		if err != nil {
+			return 0, err
+		}
+		if other, ok := imported[u]; ok {
+			if other != integrity {
+				return 0, fmt.Errorf("'%s' is listed with different checksums in '%s'", c.name, source)
+			}
+			continue
+		}
+		imported[u] = integrity
+		r := Resource{
+			Urls:      []string{u},
+			Integrity: integrity,
+			Tags:      opts.Tags,
+			Mode:      mode,
+			Headers:   opts.Headers,
+		}
+		if isUrl {
+			r.ChecksumsUrl = source
+		}
+		resources = append(resources, r)
+	}
+
+	if sample > len(resources) {
+		sample = len(resources)
+	}
+	for _, i := range rand.Perm(len(resources))[:sample] {
+		r := resources[i]
+		log.Info().Str("URL", r.Urls[0]).Msg("Spot-checking")
+		p, err := GetUrltoTempFile(r.Urls[0], r.Headers, ctx)
+		if err != nil {
+			return 0, err
+		}
+		algo, err := getAlgoFromIntegrity(r.Integrity)
+		if err == nil {
+			err = checkIntegrityFromFile(p, algo, r.Integrity, r.Urls[0])
+		}
+		os.Remove(p)
+		if err != nil {
+			return 0, err
+		}
+	}
+	l.conf.Resource = append(l.conf.Resource, resources...)
+	return len(resources), nil
+}
+
+// joinUrl resolves a slash-separated relative file name against a base url.
+func joinUrl(baseUrl string, name string) (string, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseUrl, "/") + "/")
+	if err != nil {
+		return "", fmt.Errorf("invalid base url '%s': %s", baseUrl, err)
+	}
+	segments := strings.Split(name, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	rel, err := url.Parse(strings.Join(segments, "/"))
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(rel).String(), nil
+}
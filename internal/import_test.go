@@ -0,0 +1,89 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportChecksums(t *testing.T) {
+	sums := fmt.Sprintf("%s  test.html\n%s  dir/other file.html\n", abcdefSha256, abcdefSha256)
+	requests := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if r.URL.Path == "/release/SHA256SUMS" {
+			_, err = w.Write([]byte(sums))
+		} else {
+			requests++
+			_, err = w.Write([]byte(`abcdef`))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	sumsUrl := fmt.Sprintf("http://localhost:%d/release/SHA256SUMS", port)
+
+	lock, err := NewLock(tmpFile(t, ""), false)
+	assert.Nil(t, err)
+	count, err := lock.ImportChecksums(sumsUrl, "", ResourceOptions{Tags: []string{"release"}}, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, Resource{
+		Urls:         []string{fmt.Sprintf("http://localhost:%d/release/test.html", port)},
+		Integrity:    "sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE=",
+		Tags:         []string{"release"},
+		ChecksumsUrl: sumsUrl,
+	}, lock.conf.Resource[0])
+	assert.Equal(t, []string{fmt.Sprintf("http://localhost:%d/release/dir/other%%20file.html", port)}, lock.conf.Resource[1].Urls)
+
+	// Existing resources are skipped.
+	count, err = lock.ImportChecksums(sumsUrl, "", ResourceOptions{}, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+
+	// Local files require a base url.
+	local := tmpFile(t, sums)
+	_, err = lock.ImportChecksums(local, "", ResourceOptions{}, 0)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "base url is required")
+
+	// Spot-checks detect mismatching manifests.
+	local = tmpFile(t, strings.Repeat("0", 64)+"  test.html\n")
+	_, err = lock.ImportChecksums(local, fmt.Sprintf("http://localhost:%d/mirror", port), ResourceOptions{}, 1)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "integrity mismatch")
+	assert.Equal(t, 2, len(lock.conf.Resource))
+}
+
+func TestImportChecksumsDuplicates(t *testing.T) {
+	lock, err := NewLock(tmpFile(t, ""), false)
+	assert.Nil(t, err)
+	local := tmpFile(t, fmt.Sprintf("%s  test.html\n%s *test.html\n", abcdefSha256, abcdefSha256))
+	count, err := lock.ImportChecksums(local, "http://localhost:123456/release", ResourceOptions{}, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 1, len(lock.conf.Resource))
+
+	local = tmpFile(t, fmt.Sprintf("%s  other.html\n%s  other.html\n", abcdefSha256, strings.Repeat("0", 64)))
+	_, err = lock.ImportChecksums(local, "http://localhost:123456/release", ResourceOptions{}, 0)
+	assert.ErrorContains(t, err, "'other.html' is listed with different checksums")
+	assert.Equal(t, 1, len(lock.conf.Resource))
+}
+
+func TestImportChecksumsNegativeSample(t *testing.T) {
+	lock, err := NewLock(tmpFile(t, ""), false)
+	assert.Nil(t, err)
+	local := tmpFile(t, fmt.Sprintf("%s  test.html\n", abcdefSha256))
+	_, err = lock.ImportChecksums(local, "http://localhost:123456/release", ResourceOptions{}, -1)
+	assert.ErrorContains(t, err, "invalid sample size -1")
+	assert.Equal(t, 0, len(lock.conf.Resource))
+}
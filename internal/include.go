@@ -0,0 +1,111 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+)
+
+// pin records the integrity a url is pinned to and the lock file pinning
+// it.
+type pin struct {
+	integrity string
+	path      string
+}
+
+// resources returns the resources of this lock file followed by the ones
+// of the lock files it includes.
+func (l *Lock) resources() []Resource {
+	if len(l.included) == 0 {
+		return l.conf.Resource
+	}
+	return append(append([]Resource{}, l.conf.Resource...), l.included...)
+}
+
+// loadIncludes loads the lock files included by this one, whose paths are
+// relative to its directory and whose format is detected from their
+// extension, and merges their resources. loading holds the lock files
+// being loaded to detect include cycles.
+func (l *Lock) loadIncludes(loading map[string]bool) error {
+	l.pins = map[string]pin{}
+	l.includedUrls = map[string]string{}
+	for _, r := range l.conf.Resource {
+		l.pinUrls(r, l.path)
+	}
+	for _, include := range l.conf.Include {
+		path := include
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(l.path), path)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if loading[abs] {
+			return fmt.Errorf("include cycle: '%s' includes '%s' which is already being loaded", l.path, path)
+		}
+		child, err := newLock(path, formatFromExt(path), false, loading)
+		if err != nil {
+			return fmt.Errorf("cannot include '%s' in '%s': %s", include, l.path, err)
+		}
+		err = l.merge(child.resources(), path, child.pins)
+		if err != nil {
+			return err
+		}
+		for _, f := range append([]string{path}, child.includedFiles...) {
+			if !slices.Contains(l.includedFiles, f) {
+				l.includedFiles = append(l.includedFiles, f)
+			}
+		}
+	}
+	return nil
+}
+
+// pinUrls records the integrity of the urls of the given resource defined in
+// the given lock file, unless they are already pinned.
+func (l *Lock) pinUrls(r Resource, path string) {
+	for _, u := range r.Urls {
+		if _, ok := l.pins[u]; !ok {
+			l.pins[u] = pin{r.Integrity, path}
+		}
+	}
+}
+
+// merge adds the resources of an included lock file, whose urls were
+// pinned by the lock files recorded in pins, to the ones of this lock
+// file. A url pinned to different integrities is a conflict, the
+// resources already defined with the same integrity are skipped.
+func (l *Lock) merge(resources []Resource, path string, pins map[string]pin) error {
+	for _, r := range resources {
+		origin := path
+		if len(r.Urls) > 0 {
+			if p, ok := pins[r.Urls[0]]; ok {
+				origin = p.path
+			}
+		}
+		duplicate := false
+		for _, u := range r.Urls {
+			other, ok := l.pins[u]
+			if !ok {
+				continue
+			}
+			if other.integrity != r.Integrity {
+				return fmt.Errorf("url '%s' is pinned to '%s' in '%s' and to '%s' in '%s'", u, other.integrity, other.path, r.Integrity, origin)
+			}
+			duplicate = true
+		}
+		l.pinUrls(r, origin)
+		for _, u := range r.Urls {
+			if _, ok := l.includedUrls[u]; !ok {
+				l.includedUrls[u] = origin
+			}
+		}
+		if !duplicate {
+			l.included = append(l.included, r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeLock(t *testing.T, path string, content string) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	assert.Nil(t, err)
+	err = os.WriteFile(path, []byte(content), 0644)
+	assert.Nil(t, err)
+}
+
+func TestInclude(t *testing.T) {
+	dir := tmpDir(t)
+	writeLock(t, filepath.Join(dir, "common", "grabit.lock"), `
+[[Resource]]
+Urls = ['http://localhost:123456/common.html']
+Integrity = 'sha256-YWJj'
+Tags = ['common']
+`)
+	path := filepath.Join(dir, "app", "grabit.lock")
+	writeLock(t, path, `
+Include = ['../common/grabit.lock']
+
+[[Resource]]
+Urls = ['http://localhost:123456/app.html']
+Integrity = 'sha256-YWJj'
+`)
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	assert.True(t, lock.Contains("http://localhost:123456/common.html"))
+	assert.True(t, lock.Contains("http://localhost:123456/app.html"))
+	assert.Len(t, lock.filterResources([]string{"common"}, nil), 1)
+
+	err = lock.AddResource([]string{"http://localhost:123456/common.html"}, RecommendedAlgo, ResourceOptions{})
+	assert.ErrorContains(t, err, "already present")
+	err = lock.DeleteResource("http://localhost:123456/common.html")
+	assert.ErrorContains(t, err, "defined in the included lock file '"+filepath.Join(dir, "app", "../common/grabit.lock")+"'")
+	assert.True(t, lock.Contains("http://localhost:123456/common.html"))
+	err = lock.DeleteResource("http://localhost:123456/app.html")
+	assert.Nil(t, err)
+	err = lock.Save()
+	assert.Nil(t, err)
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "../common/grabit.lock")
+	assert.NotContains(t, string(content), "common.html")
+	assert.NotContains(t, string(content), "app.html")
+}
+
+func TestIncludeNested(t *testing.T) {
+	dir := tmpDir(t)
+	writeLock(t, filepath.Join(dir, "base.lock"), `
+[[Resource]]
+Urls = ['http://localhost:123456/base.html']
+Integrity = 'sha256-YWJj'
+`)
+	writeLock(t, filepath.Join(dir, "common", "grabit.lock.json"), `{
+  "Include": ["../base.lock"],
+  "Resource": []
+}
+`)
+	path := filepath.Join(dir, "grabit.lock")
+	writeLock(t, path, `
+Include = ['common/grabit.lock.json', 'base.lock']
+Resource = []
+`)
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	assert.True(t, lock.Contains("http://localhost:123456/base.html"))
+	assert.Len(t, lock.resources(), 1)
+}
+
+func TestIncludeConflict(t *testing.T) {
+	dir := tmpDir(t)
+	writeLock(t, filepath.Join(dir, "common.lock"), `
+[[Resource]]
+Urls = ['http://localhost:123456/a.html']
+Integrity = 'sha256-YWJj'
+`)
+	path := filepath.Join(dir, "grabit.lock")
+	writeLock(t, path, `
+Include = ['common.lock']
+
+[[Resource]]
+Urls = ['http://localhost:123456/a.html']
+Integrity = 'sha256-ZGVm'
+`)
+	_, err := NewLock(path, false)
+	assert.ErrorContains(t, err, "url 'http://localhost:123456/a.html' is pinned to 'sha256-ZGVm'")
+	assert.ErrorContains(t, err, "to 'sha256-YWJj' in '"+filepath.Join(dir, "common.lock")+"'")
+}
+
+func TestIncludeCycle(t *testing.T) {
+	dir := tmpDir(t)
+	writeLock(t, filepath.Join(dir, "a.lock"), "Include = ['b.lock']\nResource = []\n")
+	writeLock(t, filepath.Join(dir, "b.lock"), "Include = ['a.lock']\nResource = []\n")
+	_, err := NewLock(filepath.Join(dir, "a.lock"), false)
+	assert.ErrorContains(t, err, "include cycle")
+}
+
+func TestIncludeMissing(t *testing.T) {
+	dir := tmpDir(t)
+	path := filepath.Join(dir, "grabit.lock")
+	writeLock(t, path, "Include = ['missing.lock']\nResource = []\n")
+	_, err := NewLock(path, false)
+	assert.ErrorContains(t, err, "cannot include 'missing.lock'")
+}
+
+func TestIncludeMixedFormats(t *testing.T) {
+	dir := tmpDir(t)
+	writeLock(t, filepath.Join(dir, "common", "grabit.lock"), `
+[[Resource]]
+Urls = ['http://localhost:123456/common.html']
+Integrity = 'sha256-YWJj'
+`)
+	writeLock(t, filepath.Join(dir, "common", "grabit.lock.yaml"), `
+Resource:
+  - Urls: ['http://localhost:123456/other.html']
+    Integrity: sha256-YWJj
+`)
+	path := filepath.Join(dir, "top.lockfile")
+	writeLock(t, path, `{
+  "Include": ["common/grabit.lock", "common/grabit.lock.yaml"],
+  "Resource": []
+}
+`)
+	err := SetLockFormat("json")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = SetLockFormat("") })
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "json", lock.format)
+	assert.True(t, lock.Contains("http://localhost:123456/common.html"))
+	assert.True(t, lock.Contains("http://localhost:123456/other.html"))
+}
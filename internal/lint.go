@@ -0,0 +1,154 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Severity is the severity of a lint issue.
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// ParseSeverity returns the severity with the given name.
+func ParseSeverity(s string) (Severity, error) {
+	switch s {
+	case "warning":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	}
+	return SeverityWarning, fmt.Errorf("unknown severity '%s' (available severities: warning, error)", s)
+}
+
+// weakAlgos are the integrity algorithms that should not be used anymore.
+var weakAlgos = map[string]bool{
+	"sha1": true,
+}
+
+// LintIssue is a problem found in a lock file.
+type LintIssue struct {
+	Severity Severity
+	// Resource is the first url of the resource the issue is about.
+	Resource string
+	Message  string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Resource, i.Message)
+}
+
+// Lint checks the resources of the lock file for errors and for
+// questionable definitions.
+func (l *Lock) Lint() []LintIssue {
+	issues := []LintIssue{}
+	urls := map[string]string{}
+	names := map[string]string{}
+	for _, r := range l.resources() {
+		id := r.firstUrl()
+		report := func(severity Severity, format string, args ...any) {
+			issues = append(issues, LintIssue{Severity: severity, Resource: id, Message: fmt.Sprintf(format, args...)})
+		}
+		for _, u := range r.Urls {
+			if other, ok := urls[u]; ok {
+				report(SeverityError, "url '%s' is also defined by '%s'", u, other)
+				continue
+			}
+			urls[u] = id
+			parsedUrl, err := url.Parse(u)
+			if err != nil {
+				report(SeverityError, "invalid url '%s': %s", u, err)
+				continue
+			}
+			if _, err := getFetcher(parsedUrl); err != nil {
+				report(SeverityError, "%s", err)
+			}
+			if parsedUrl.User != nil {
+				report(SeverityError, "url '%s' embeds credentials", parsedUrl.Redacted())
+			}
+			if scheme := strings.ToLower(parsedUrl.Scheme); scheme == "http" || scheme == "git+http" {
+				report(SeverityWarning, "url '%s' does not use HTTPS", u)
+			}
+			name := r.localName(u)
+			if msg := unsafeFileName(name); msg != "" {
+				report(SeverityError, "unsafe file name '%s': %s", name, msg)
+			} else if other, ok := names[name]; ok && other != id {
+				report(SeverityError, "file name '%s' is also used by '%s'", name, other)
+			} else {
+				names[name] = id
+			}
+		}
+		if err := lintIntegrity(r.Integrity); err != nil {
+			report(SeverityError, "integrity: %s", err)
+		}
+		if algo, err := getAlgoFromIntegrity(r.Integrity); err == nil && weakAlgos[algo] {
+			report(SeverityWarning, "weak integrity algorithm '%s', use '%s' instead", algo, RecommendedAlgo)
+		}
+		if r.Extract {
+			if r.TreeIntegrity == "" {
+				report(SeverityError, "missing tree integrity of extracted archive")
+			} else if err := lintIntegrity(r.TreeIntegrity); err != nil {
+				report(SeverityError, "tree integrity: %s", err)
+			}
+		} else if r.TreeIntegrity != "" {
+			report(SeverityWarning, "tree integrity defined but the resource is not extracted")
+		}
+		if _, err := normalizeMode(r.Mode); err != nil {
+			report(SeverityError, "%s", err)
+		}
+		if r.PublicKey != "" && r.Signature == "" {
+			report(SeverityError, "public key defined without a signature url")
+		}
+		if r.PublicKey != "" {
+			if _, err := parsePublicKeys(r.PublicKey); err != nil {
+				report(SeverityError, "invalid public key: %s", err)
+			}
+		}
+	}
+	return issues
+}
+
+// lintIntegrity checks that the given SRI is well-formed.
+func lintIntegrity(integrity string) error {
+	if integrity == "" {
+		return fmt.Errorf("missing integrity")
+	}
+	algo, digest, err := integrityDigest(integrity)
+	if err != nil {
+		return err
+	}
+	if size := algos[algo]().Size(); len(digest) != 2*size {
+		return fmt.Errorf("invalid SRI '%s': %s digests are %d bytes long", integrity, algo, size)
+	}
+	return nil
+}
+
+// unsafeFileName returns why the given file name is unsafe to download
+// resources to, or an empty string if it is safe.
+func unsafeFileName(name string) string {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return "empty file name"
+	case name == "." || name == "..":
+		return "not a file name"
+	case strings.ContainsAny(name, "/\\"):
+		return "contains a path separator"
+	case strings.ContainsRune(name, 0):
+		return "contains a NUL character"
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lintMessages(t *testing.T, content string) []string {
+	lock, err := NewLock(tmpFile(t, content), false)
+	assert.Nil(t, err)
+	messages := []string{}
+	for _, issue := range lock.Lint() {
+		messages = append(messages, issue.String())
+	}
+	return messages
+}
+
+func TestLintValid(t *testing.T) {
+	messages := lintMessages(t, `
+	[[Resource]]
+	Urls = ['https://localhost:123456/test.html']
+	Integrity = 'sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE='
+	Mode = '0755'
+`)
+	assert.Empty(t, messages)
+}
+
+func TestLintErrors(t *testing.T) {
+	messages := lintMessages(t, `
+	[[Resource]]
+	Urls = ['https://localhost:123456/a/test.html']
+	Integrity = 'md5-asdasdasd'
+
+	[[Resource]]
+	Urls = ['https://localhost:123456/b/test.html', 'https://localhost:123456/a/test.html']
+	Integrity = 'sha256-YWJj'
+
+	[[Resource]]
+	Urls = ['https://localhost:123456/c.tgz']
+	Integrity = 'sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE='
+	Filename = '../c'
+	Extract = true
+	Mode = 'rwx'
+`)
+	assert.Equal(t, []string{
+		"error: https://localhost:123456/a/test.html: integrity: unknown hash algorithm 'md5' (available algorithms: " + allAlgos + ")",
+		"error: https://localhost:123456/b/test.html: file name 'test.html' is also used by 'https://localhost:123456/a/test.html'",
+		"error: https://localhost:123456/b/test.html: url 'https://localhost:123456/a/test.html' is also defined by 'https://localhost:123456/a/test.html'",
+		"error: https://localhost:123456/b/test.html: integrity: invalid SRI 'sha256-YWJj': sha256 digests are 32 bytes long",
+		"error: https://localhost:123456/c.tgz: unsafe file name '../c': contains a path separator",
+		"error: https://localhost:123456/c.tgz: missing tree integrity of extracted archive",
+		"error: https://localhost:123456/c.tgz: 'rwx' is not a valid permission definition",
+	}, messages)
+}
+
+func TestLintWarnings(t *testing.T) {
+	messages := lintMessages(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/test.html']
+	Integrity = 'sha1-H4rBDyPFtbwRZ72oS4M+XAV6d9I='
+`)
+	assert.Equal(t, []string{
+		"warning: http://localhost:123456/test.html: url 'http://localhost:123456/test.html' does not use HTTPS",
+		"warning: http://localhost:123456/test.html: weak integrity algorithm 'sha1', use 'sha256' instead",
+	}, messages)
+}
+
+func TestParseSeverity(t *testing.T) {
+	s, err := ParseSeverity("warning")
+	assert.Nil(t, err)
+	assert.Equal(t, SeverityWarning, s)
+	s, err = ParseSeverity("error")
+	assert.Nil(t, err)
+	assert.Equal(t, SeverityError, s)
+	_, err = ParseSeverity("fatal")
+	assert.NotNil(t, err)
+}
@@ -5,56 +5,116 @@ package internal
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
-	toml "github.com/pelletier/go-toml/v2"
+	"github.com/rs/zerolog/log"
 )
 
-var COMMENT_PREFIX = "//"
+var COMMENT_PREFIX = "#"
 
 // Lock represents a grabit lockfile.
 type Lock struct {
-	path string
-	conf config
+	path     string
+	format   string
+	conf     config
+	comments lockComments
+	// included holds the resources of the included lock files, which are
+	// not saved with this one, and pins the lock file pinning each url.
+	// includedUrls maps the urls defined by the included lock files to
+	// the one defining them, and includedFiles lists these files.
+	included      []Resource
+	pins          map[string]pin
+	includedUrls  map[string]string
+	includedFiles []string
+	// loaded is the content of the lock file when it was loaded, nil if
+	// it did not exist.
+	loaded []byte
+}
+
+// AcquireLock takes an advisory lock preventing other grabit processes
+// from modifying the given lock file until the returned function is
+// called. It must be taken before loading a lock file to update it.
+func AcquireLock(path string) (func(), error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	release, err := lockDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot lock '%s': %s", path, err)
+	}
+	return release, nil
 }
 
 type config struct {
-	Resource []Resource
+	Version  int        `toml:",omitempty" json:",omitempty" yaml:"Version,omitempty"`
+	Include  []string   `toml:",omitempty" json:",omitempty" yaml:"Include,omitempty"`
+	Resource []Resource `yaml:"Resource"`
 }
 
 func NewLock(path string, newOk bool) (*Lock, error) {
+	return newLock(path, formatOf(path), newOk, map[string]bool{})
+}
+
+// newLock loads the given lock file, in the given format, and the lock
+// files it includes, loading holds the lock files being loaded.
+func newLock(path string, format string, newOk bool, loading map[string]bool) (*Lock, error) {
+	if _, ok := lockFormats[format]; !ok {
+		return nil, unknownFormatError(format)
+	}
 	_, error := os.Stat(path)
 	if os.IsNotExist(error) {
 		if newOk {
-			return &Lock{path: path}, nil
+			return &Lock{path: path, format: format, conf: config{Version: LockVersion}}, nil
 		} else {
 			return nil, fmt.Errorf("file '%s' does not exist", path)
 		}
 	}
-	var conf config
-	file, err := os.Open(path)
+	content, err := os.ReadFile(path)
 	if err != nil {
-		return nil, error
+		return nil, err
 	}
-	d := toml.NewDecoder(file)
-	err = d.Decode(&conf)
+	conf, err := decodeConfig(content, path, format)
 	if err != nil {
 		return nil, err
 	}
-
-	return &Lock{path: path, conf: conf}, nil
+	l := &Lock{path: path, format: format, conf: conf, loaded: content}
+	switch format {
+	case "toml":
+		l.comments = parseComments(content, conf.Resource)
+	case "yaml":
+		l.comments.unsupported = hasYamlComments(content)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	loading[abs] = true
+	defer delete(loading, abs)
+	err = l.loadIncludes(loading)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
 }
 
-func (l *Lock) AddResource(paths []string, algo string, tags []string, filename string) error {
+func (l *Lock) AddResource(paths []string, algo string, opts ResourceOptions) error {
 	for _, u := range paths {
 		if l.Contains(u) {
 			return fmt.Errorf("resource '%s' is already present", u)
 		}
 	}
-	r, err := NewResourceFromUrl(paths, algo, tags, filename)
+	r, err := NewResourceFromUrl(paths, algo, opts)
 	if err != nil {
 		return err
 	}
@@ -62,7 +122,12 @@ func (l *Lock) AddResource(paths []string, algo string, tags []string, filename
 	return nil
 }
 
-func (l *Lock) DeleteResource(path string) {
+// DeleteResource removes the resource with the given url from this lock
+// file. The resources of the included lock files cannot be deleted.
+func (l *Lock) DeleteResource(path string) error {
+	if origin, ok := l.includedUrls[path]; ok {
+		return fmt.Errorf("resource '%s' is defined in the included lock file '%s'", path, origin)
+	}
 	newStatements := []Resource{}
 	for _, r := range l.conf.Resource {
 		if !r.Contains(path) {
@@ -70,6 +135,7 @@ func (l *Lock) DeleteResource(path string) {
 		}
 	}
 	l.conf.Resource = newStatements
+	return nil
 }
 
 const NoFileMode = os.FileMode(0)
@@ -86,23 +152,78 @@ func strToFileMode(perm string) (os.FileMode, error) {
 	return os.FileMode(parsed), nil
 }
 
+// ResourceResult describes the outcome of an operation on a resource.
+type ResourceResult struct {
+	Resource Resource
+	// Url is the url the resource was downloaded from and Path the
+	// file or directory it was installed to.
+	Url      string
+	Path     string
+	Bytes    int64
+	Started  time.Time
+	Finished time.Time
+	Err      error
+}
+
 // Download gets all the resources in this lock file and moves them to
-// the destination directory.
-func (l *Lock) Download(dir string, tags []string, notags []string, perm string) error {
+// the destination directory. When a store is given, the resources are
+// kept in it and linked from the destination directory. The first error
+// cancels the remaining downloads, the results of all the resources are
+// returned along with it.
+func (l *Lock) Download(dir string, tags []string, notags []string, perm string, store *Store) ([]ResourceResult, error) {
 	if stat, err := os.Stat(dir); err != nil || !stat.IsDir() {
-		return fmt.Errorf("'%s' is not a directory", dir)
+		return nil, fmt.Errorf("'%s' is not a directory", dir)
 	}
 	mode, err := strToFileMode(perm)
 	if err != nil {
-		return fmt.Errorf("'%s' is not a valid permission definition", perm)
+		return nil, fmt.Errorf("'%s' is not a valid permission definition", perm)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	filteredResources := l.filterResources(tags, notags)
+	total := len(filteredResources)
+	if total == 0 {
+		return nil, fmt.Errorf("nothing to download")
+	}
+	resultCh := make(chan ResourceResult, total)
+	for _, r := range filteredResources {
+		resource := r
+		go func() {
+			result := ResourceResult{Resource: resource, Started: time.Now()}
+			u, err := resource.Download(dir, mode, store, ctx)
+			if err == nil {
+				result.Url = u
+				result.Path = filepath.Join(dir, resource.localName(u))
+				result.Bytes, err = installedSize(result.Path)
+			}
+			result.Finished = time.Now()
+			result.Err = err
+			resultCh <- result
+		}()
+	}
+	results := make([]ResourceResult, 0, total)
+	var firstErr error
+	for result := range resultCh {
+		if result.Err != nil && firstErr == nil {
+			firstErr = result.Err
+			cancel()
+		}
+		results = append(results, result)
+		if len(results) == total {
+			break
+		}
+	}
+	return results, firstErr
+}
+
+// filterResources returns the resources that have all the given tags
+// and none of the given notags.
+func (l *Lock) filterResources(tags []string, notags []string) []Resource {
 	// Filter in the resources that have all the required tags.
 	tagFilteredResources := []Resource{}
 	if len(tags) > 0 {
-		for _, r := range l.conf.Resource {
+		for _, r := range l.resources() {
 			hasAllTags := true
 			for _, tag := range tags {
 				hasTag := false
@@ -122,7 +243,7 @@ func (l *Lock) Download(dir string, tags []string, notags []string, perm string)
 			}
 		}
 	} else {
-		tagFilteredResources = l.conf.Resource
+		tagFilteredResources = l.resources()
 	}
 	// Filter out the resources that have any 'notag' tag.
 	filteredResources := []Resource{}
@@ -143,56 +264,148 @@ func (l *Lock) Download(dir string, tags []string, notags []string, perm string)
 	} else {
 		filteredResources = tagFilteredResources
 	}
+	return filteredResources
+}
 
-	total := len(filteredResources)
-	if total == 0 {
-		return fmt.Errorf("nothing to download")
+// Verify checks that the resources downloaded in the given directory
+// match their integrity.
+func (l *Lock) Verify(dir string, tags []string, notags []string) ([]ResourceResult, error) {
+	filteredResources := l.filterResources(tags, notags)
+	if len(filteredResources) == 0 {
+		return nil, fmt.Errorf("nothing to verify")
 	}
-	errorCh := make(chan error, total)
+	failed := 0
+	results := make([]ResourceResult, 0, len(filteredResources))
 	for _, r := range filteredResources {
-		resource := r
-		go func() {
-			err := resource.Download(dir, mode, ctx)
-			errorCh <- err
-		}()
-	}
-	done := 0
-	for err := range errorCh {
-		if err != nil {
-			return err
-		}
-		done += 1
-		if done == total {
-			return nil
+		result := ResourceResult{Resource: r, Started: time.Now()}
+		result.Err = r.Verify(dir)
+		result.Finished = time.Now()
+		if result.Err != nil {
+			log.Error().Str("Resource", r.Urls[0]).Msg(result.Err.Error())
+			failed += 1
+		} else {
+			log.Debug().Str("Resource", r.Urls[0]).Msg("Verified")
 		}
+		results = append(results, result)
 	}
-	return nil
+	if failed > 0 {
+		return results, fmt.Errorf("%d out of %d resources failed verification", failed, len(filteredResources))
+	}
+	return results, nil
+}
+
+// List writes a table describing the resources that have all the given
+// tags and none of the given notags.
+func (l *Lock) List(w io.Writer, tags []string, notags []string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tVERSION\tLICENSE\tURL\tTAGS")
+	for _, r := range l.filterResources(tags, notags) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.name(), r.Version, r.License, r.firstUrl(), strings.Join(r.Tags, ","))
+	}
+	return tw.Flush()
+}
+
+// sortedResources returns the given resources in their canonical order,
+// sorted by url.
+func sortedResources(resources []Resource) []Resource {
+	sorted := append([]Resource{}, resources...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return strings.Join(sorted[i].Urls, "\n") < strings.Join(sorted[j].Urls, "\n")
+	})
+	return sorted
+}
+
+// serialize returns the canonical content of this lock file, which does not
+// depend on the order the resources were added in, along with its
+// comments.
+func (l *Lock) serialize() ([]byte, error) {
+	if _, err := l.Migrate(); err != nil {
+		return nil, err
+	}
+	conf := l.conf
+	conf.Resource = sortedResources(l.conf.Resource)
+	res, err := lockFormats[l.format].encode(conf)
+	if err != nil {
+		return nil, err
+	}
+	if l.format != "toml" {
+		return res, nil
+	}
+	return l.comments.apply(res, conf.Resource), nil
+}
+
+// IsFormatted returns true if this lock file is saved in its canonical
+// form.
+func (l *Lock) IsFormatted() (bool, error) {
+	content, err := os.ReadFile(l.path)
+	if err != nil {
+		return false, err
+	}
+	formatted, err := l.serialize()
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(content, formatted), nil
 }
 
-// Save this lock file to disk.
+// Save this lock file to disk in its canonical form, upgrading it to the
+// current version of the lock file format. The file is replaced
+// atomically and Save fails if it was modified since it was loaded.
 func (l *Lock) Save() error {
-	res, err := toml.Marshal(l.conf)
+	res, err := l.serialize()
 	if err != nil {
 		return err
 	}
-	file, err := os.Create(l.path)
+	if l.comments.dropped(l.format) {
+		log.Warn().Str("Path", l.path).Msg("Comments are only kept in TOML lock files, the comments of the lock file are dropped")
+	}
+	current, err := os.ReadFile(l.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if (err == nil) != (l.loaded != nil) || !bytes.Equal(current, l.loaded) {
+		return fmt.Errorf("lock file '%s' was modified by another process since it was loaded", l.path)
+	}
+	dir := filepath.Dir(l.path)
+	file, err := os.CreateTemp(dir, fmt.Sprintf(".%s.*.tmp", filepath.Base(l.path)))
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	defer os.Remove(file.Name())
 	w := bufio.NewWriter(file)
 	_, err = w.Write(res)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write lock file '%s': %s", l.path, err)
+	}
+	perm := os.FileMode(0644)
+	if stat, err := os.Stat(l.path); err == nil {
+		perm = stat.Mode().Perm()
+	}
+	err = os.Chmod(file.Name(), perm)
 	if err != nil {
 		return err
 	}
-	w.Flush()
-	return nil
+	err = os.Rename(file.Name(), l.path)
+	if err != nil {
+		return err
+	}
+	l.loaded = res
+	return syncDir(dir)
 }
 
-// Contains returns true if this lock file contains the
-// given resource url.
+// Contains returns true if this lock file, or a lock file it includes,
+// contains the given resource url.
 func (l *Lock) Contains(url string) bool {
-	for _, r := range l.conf.Resource {
+	for _, r := range l.resources() {
 		for _, u := range r.Urls {
 			if url == u {
 				return true
@@ -8,7 +8,9 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -50,12 +52,13 @@ func TestLockManipulations(t *testing.T) {
 	port, server := httpHandler(handler)
 	defer server.Close()
 	resource := fmt.Sprintf("http://localhost:%d/test2.html", port)
-	err = lock.AddResource([]string{resource}, "sha512", []string{}, "")
+	err = lock.AddResource([]string{resource}, "sha512", ResourceOptions{})
 	assert.Nil(t, err)
 	assert.Equal(t, 2, len(lock.conf.Resource))
 	err = lock.Save()
 	assert.Nil(t, err)
-	lock.DeleteResource(resource)
+	err = lock.DeleteResource(resource)
+	assert.Nil(t, err)
 	assert.Equal(t, 1, len(lock.conf.Resource))
 }
 
@@ -67,7 +70,7 @@ func TestDuplicateResource(t *testing.T) {
 		Integrity = 'sha256-asdasdasd'`, url))
 	lock, err := NewLock(path, false)
 	assert.Nil(t, err)
-	err = lock.AddResource([]string{url}, "sha512", []string{}, "")
+	err = lock.AddResource([]string{url}, "sha512", ResourceOptions{})
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "already present")
 }
@@ -114,7 +117,7 @@ func TestDownload(t *testing.T) {
 	lock, err := NewLock(path, false)
 	assert.Nil(t, err)
 	dir := tmpDir(t)
-	err = lock.Download(dir, []string{}, []string{}, perm)
+	_, err = lock.Download(dir, []string{}, []string{}, perm, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -131,3 +134,158 @@ func TestDownload(t *testing.T) {
 	}
 	assert.Equal(t, stats.Mode().Perm().String(), strPerm)
 }
+
+func TestDownloadResourceMode(t *testing.T) {
+	httpContent := []byte(`abcdef`)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write(httpContent)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	path := tmpFile(t, fmt.Sprintf(`
+		[[Resource]]
+		Urls = ['http://localhost:%d/test.sh']
+		Integrity = 'sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE='
+		Mode = '0755'`, port))
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	dir := tmpDir(t)
+	_, err = lock.Download(dir, []string{}, []string{}, "644", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := os.Stat(filepath.Join(dir, "test.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "-rwxr-xr-x", stats.Mode().Perm().String())
+}
+
+func TestLockList(t *testing.T) {
+	path := tmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/test.html']
+	Integrity = 'sha256-asdasdasd'
+	Tags = ['tag1', 'tag2']
+	Name = 'test'
+	Version = '1.2.3'
+	License = 'MIT'
+
+	[[Resource]]
+	Urls = ['http://localhost:123456/other.html']
+	Integrity = 'sha256-asdasdasd'
+`)
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	var buf strings.Builder
+	err = lock.List(&buf, []string{}, []string{})
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 3, len(lines))
+	assert.Equal(t, []string{"NAME", "VERSION", "LICENSE", "URL", "TAGS"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"test", "1.2.3", "MIT", "http://localhost:123456/test.html", "tag1,tag2"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"other.html", "http://localhost:123456/other.html"}, strings.Fields(lines[2]))
+	buf.Reset()
+	err = lock.List(&buf, []string{}, []string{"tag1"})
+	assert.Nil(t, err)
+	assert.NotContains(t, buf.String(), "test.html")
+}
+
+func TestSaveCanonicalOrder(t *testing.T) {
+	path := tmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/b.html']
+	Integrity = 'sha256-asdasdasd'
+
+	[[Resource]]
+	Urls = ['http://localhost:123456/a.html']
+	Integrity = 'sha256-asdasdasd'
+`)
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	formatted, err := lock.IsFormatted()
+	assert.Nil(t, err)
+	assert.False(t, formatted)
+	err = lock.Save()
+	assert.Nil(t, err)
+	formatted, err = lock.IsFormatted()
+	assert.Nil(t, err)
+	assert.True(t, formatted)
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Less(t, strings.Index(string(content), "a.html"), strings.Index(string(content), "b.html"))
+}
+
+func TestSaveConcurrentModification(t *testing.T) {
+	path := tmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/a.html']
+	Integrity = 'sha256-asdasdasd'
+`)
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	other, err := NewLock(path, false)
+	assert.Nil(t, err)
+	err = other.DeleteResource("http://localhost:123456/a.html")
+	assert.Nil(t, err)
+	err = other.Save()
+	assert.Nil(t, err)
+	err = lock.Save()
+	assert.ErrorContains(t, err, "modified by another process")
+	// Saving twice from the same lock is fine.
+	err = other.Save()
+	assert.Nil(t, err)
+}
+
+func TestSaveNewConcurrentModification(t *testing.T) {
+	path := filepath.Join(tmpDir(t), "grabit.lock")
+	lock, err := NewLock(path, true)
+	assert.Nil(t, err)
+	err = os.WriteFile(path, []byte(""), 0644)
+	assert.Nil(t, err)
+	err = lock.Save()
+	assert.ErrorContains(t, err, "modified by another process")
+}
+
+func TestSaveAtomic(t *testing.T) {
+	path := tmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/a.html']
+	Integrity = 'sha256-asdasdasd'
+`)
+	err := os.Chmod(path, 0600)
+	assert.Nil(t, err)
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	err = lock.Save()
+	assert.Nil(t, err)
+	stat, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+}
+
+func TestAcquireLock(t *testing.T) {
+	path := filepath.Join(tmpDir(t), "grabit.lock")
+	release, err := AcquireLock(path)
+	assert.Nil(t, err)
+	acquired := make(chan struct{})
+	go func() {
+		release, err := AcquireLock(path)
+		assert.Nil(t, err)
+		close(acquired)
+		release()
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("lock acquired twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+	<-acquired
+}
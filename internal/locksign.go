@@ -0,0 +1,216 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SignaturePath returns the path of the detached signature of the lock file.
+func (l *Lock) SignaturePath() string {
+	return l.path + ".sig"
+}
+
+// LockSignatureVersion is the version of the canonical encoding of the
+// lock files signed by this version of grabit.
+const LockSignatureVersion = 1
+
+// signedResource is the fixed set of fields of a resource covered by a
+// lock file signature, in the order they are encoded in.
+type signedResource struct {
+	Urls          []string
+	Integrity     string
+	Tags          []string
+	Filename      string
+	Mode          string
+	Headers       map[string]string
+	Extract       bool
+	TreeIntegrity string
+	Signature     string
+	PublicKey     string
+	ChecksumsUrl  string
+	Name          string
+	Version       string
+	License       string
+	Supplier      string
+	Homepage      string
+	Description   string
+}
+
+// canonicalConfig returns the serialization of the lock file content that
+// is signed. It does not depend on the formatting of the file nor on the
+// order of the resources, and covers the resources of the included lock
+// files so that they cannot be modified without invalidating the
+// signature.
+//
+// It is the line "grabit-lock-signature-v1" followed by the JSON object
+// {"Resource": [...]} of the fields of signedResource. The encoding must
+// not change once released: new fields require a new version.
+func (l *Lock) canonicalConfig() ([]byte, error) {
+	signed := struct{ Resource []signedResource }{}
+	for _, r := range sortedResources(l.resources()) {
+		signed.Resource = append(signed.Resource, signedResource{
+			Urls:          r.Urls,
+			Integrity:     r.Integrity,
+			Tags:          r.Tags,
+			Filename:      r.Filename,
+			Mode:          r.Mode,
+			Headers:       r.Headers,
+			Extract:       r.Extract,
+			TreeIntegrity: r.TreeIntegrity,
+			Signature:     r.Signature,
+			PublicKey:     r.PublicKey,
+			ChecksumsUrl:  r.ChecksumsUrl,
+			Name:          r.Name,
+			Version:       r.Version,
+			License:       r.License,
+			Supplier:      r.Supplier,
+			Homepage:      r.Homepage,
+			Description:   r.Description,
+		})
+	}
+	content, err := json.Marshal(signed)
+	if err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf("grabit-lock-signature-v%d\n", LockSignatureVersion)
+	return append([]byte(header), content...), nil
+}
+
+// encodeLockSignature returns the content of the signature file of the
+// given signature, prefixed by the version of the canonical encoding.
+func encodeLockSignature(sig []byte) string {
+	return fmt.Sprintf("v%d:%s\n", LockSignatureVersion, base64.StdEncoding.EncodeToString(sig))
+}
+
+// decodeLockSignature parses the content of a signature file, which must
+// have been made with the current version of the canonical encoding.
+func decodeLockSignature(content string) ([]byte, error) {
+	prefix, encoded, ok := strings.Cut(strings.TrimSpace(content), ":")
+	if !ok || !strings.HasPrefix(prefix, "v") {
+		return nil, fmt.Errorf("missing signature version")
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(prefix, "v"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature version '%s'", prefix)
+	}
+	if version != LockSignatureVersion {
+		return nil, fmt.Errorf("unsupported lock file signature version %d", version)
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// GenerateSigningKey creates an Ed25519 key pair to sign lock files and
+// writes the PEM encoded private and public keys to the given paths.
+func GenerateSigningKey(privatePath string, publicPath string) error {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	privDer, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	pubDer, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDer}), 0600)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDer}), 0644)
+}
+
+// Sign writes a detached signature of the lock file content made with
+// the given PEM private key (Ed25519, ECDSA or RSA).
+func (l *Lock) Sign(privatePath string) error {
+	content, err := os.ReadFile(privatePath)
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return fmt.Errorf("'%s' is not a PEM encoded PKCS #8 private key", privatePath)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid private key '%s': %s", privatePath, err)
+	}
+	data, err := l.canonicalConfig()
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(data)
+	var sig []byte
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, data)
+	case *ecdsa.PrivateKey:
+		sig, err = ecdsa.SignASN1(rand.Reader, k, digest[:])
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	default:
+		return fmt.Errorf("unsupported private key type %T", key)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.SignaturePath(), []byte(encodeLockSignature(sig)), 0644)
+}
+
+// VerifySignature checks that the detached signature of the lock file
+// was made by one of the given PEM public keys over its current content.
+func (l *Lock) VerifySignature(trustedKeys []string) error {
+	if len(trustedKeys) == 0 {
+		return fmt.Errorf("no trusted key to verify the lock file signature")
+	}
+	var keys []publicKey
+	for _, path := range trustedKeys {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		parsed, err := parsePublicKeys(string(content))
+		if err != nil {
+			return fmt.Errorf("invalid trusted key '%s': %s", path, err)
+		}
+		keys = append(keys, parsed...)
+	}
+	encoded, err := os.ReadFile(l.SignaturePath())
+	if os.IsNotExist(err) {
+		return fmt.Errorf("lock file '%s' is not signed", l.path)
+	}
+	if err != nil {
+		return err
+	}
+	sig, err := decodeLockSignature(string(encoded))
+	if err != nil {
+		return fmt.Errorf("invalid lock file signature '%s': %s", l.SignaturePath(), err)
+	}
+	data, err := l.canonicalConfig()
+	if err != nil {
+		return err
+	}
+	signed := &signedData{data: data}
+	for _, k := range keys {
+		if k.pkix != nil && verifyPkix(signed, sig, k.pkix) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("lock file '%s' signature does not match any trusted key, the lock file may have been tampered with", l.path)
+}
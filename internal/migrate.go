@@ -0,0 +1,86 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import "fmt"
+
+// LockVersion is the version of the lock file schema written by this
+// version of grabit. Lock files without a version predate versioning.
+const LockVersion = 1
+
+// migrations upgrade the configuration of a lock file from the version
+// matching their index to the next one.
+var migrations = []func(conf *config) error{
+	// Version 1 records the permissions of the resources in their
+	// canonical 4 digits octal form.
+	func(conf *config) error {
+		for i := range conf.Resource {
+			mode, err := normalizeMode(conf.Resource[i].Mode)
+			if err != nil {
+				return fmt.Errorf("resource '%s': %s", conf.Resource[i].Urls, err)
+			}
+			conf.Resource[i].Mode = mode
+		}
+		return nil
+	},
+}
+
+func init() {
+	if len(migrations) != LockVersion {
+		panic(fmt.Sprintf("%d lock file migrations defined for version %d", len(migrations), LockVersion))
+	}
+}
+
+// decodeConfig decodes a lock file in the given format, rejecting the
+// fields and versions this version of grabit does not know about.
+func decodeConfig(content []byte, path string, format string) (config, error) {
+	var conf config
+	codec, ok := lockFormats[format]
+	if !ok {
+		return conf, unknownFormatError(format)
+	}
+	err := codec.decode(content, &conf, true)
+	if err != nil {
+		// The lock file is valid but has unknown fields if it can be
+		// decoded leniently.
+		var lenient config
+		if codec.decode(content, &lenient, false) != nil {
+			return conf, fmt.Errorf("invalid lock file '%s': %s", path, err)
+		}
+		if lenient.Version > LockVersion {
+			return conf, newerVersionError(path, lenient.Version)
+		}
+		return conf, fmt.Errorf("invalid lock file '%s': %s, it may have been written by a newer version of grabit, please upgrade grabit", path, err)
+	}
+	if conf.Version > LockVersion {
+		return conf, newerVersionError(path, conf.Version)
+	}
+	if conf.Version < 0 {
+		return conf, fmt.Errorf("invalid lock file '%s': invalid version %d", path, conf.Version)
+	}
+	for i, r := range conf.Resource {
+		if len(r.Urls) == 0 {
+			return conf, fmt.Errorf("invalid lock file '%s': resource #%d has an empty url list", path, i+1)
+		}
+	}
+	return conf, nil
+}
+
+func newerVersionError(path string, version int) error {
+	return fmt.Errorf("lock file '%s' uses version %d of the lock file format but this version of grabit only supports up to version %d, please upgrade grabit", path, version, LockVersion)
+}
+
+// Migrate upgrades the lock file to the current version of the lock file
+// format and returns the version it was upgraded from.
+func (l *Lock) Migrate() (int, error) {
+	from := l.conf.Version
+	for v := from; v < LockVersion; v++ {
+		err := migrations[v](&l.conf)
+		if err != nil {
+			return from, fmt.Errorf("failed to migrate lock file '%s' to version %d: %s", l.path, v+1, err)
+		}
+		l.conf.Version = v + 1
+	}
+	return from, nil
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLockUnknownField(t *testing.T) {
+	path := tmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/test.html']
+	Integrity = 'sha256-asdasdasd'
+	Size = 12
+`)
+	_, err := NewLock(path, false)
+	assert.ErrorContains(t, err, "Resource.Size")
+	assert.ErrorContains(t, err, "upgrade grabit")
+}
+
+func TestNewLockNewerVersion(t *testing.T) {
+	path := tmpFile(t, fmt.Sprintf(`
+	Version = %d
+
+	[[Resource]]
+	Urls = ['http://localhost:123456/test.html']
+	Integrity = 'sha256-asdasdasd'
+	Sizes = [12]
+`, LockVersion+1))
+	_, err := NewLock(path, false)
+	assert.ErrorContains(t, err, fmt.Sprintf("uses version %d", LockVersion+1))
+	assert.ErrorContains(t, err, "upgrade grabit")
+}
+
+func TestNewLockNegativeVersion(t *testing.T) {
+	path := tmpFile(t, `
+	Version = -1
+
+	[[Resource]]
+	Urls = ['http://localhost:123456/test.html']
+	Integrity = 'sha256-asdasdasd'
+`)
+	_, err := NewLock(path, false)
+	assert.ErrorContains(t, err, "invalid version -1")
+}
+
+func TestMigrate(t *testing.T) {
+	path := tmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/test.sh']
+	Integrity = 'sha256-asdasdasd'
+	Mode = '755'
+`)
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, lock.conf.Version)
+	from, err := lock.Migrate()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, from)
+	assert.Equal(t, LockVersion, lock.conf.Version)
+	assert.Equal(t, "0755", lock.conf.Resource[0].Mode)
+	err = lock.Save()
+	assert.Nil(t, err)
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), fmt.Sprintf("Version = %d", LockVersion))
+	lock, err = NewLock(path, false)
+	assert.Nil(t, err)
+	from, err = lock.Migrate()
+	assert.Nil(t, err)
+	assert.Equal(t, LockVersion, from)
+}
+
+func TestNewLockVersion(t *testing.T) {
+	lock, err := NewLock(tmpFile(t, ""), false)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, lock.conf.Version)
+	lock, err = NewLock("/u/d/x/new.lock", true)
+	assert.Nil(t, err)
+	assert.Equal(t, LockVersion, lock.conf.Version)
+}
+
+func TestNewLockEmptyUrls(t *testing.T) {
+	path := tmpFile(t, `
+	[[Resource]]
+	Urls = ['http://localhost:123456/test.html']
+	Integrity = 'sha256-asdasdasd'
+
+	[[Resource]]
+	Urls = []
+	Integrity = 'sha256-asdasdasd'
+`)
+	_, err := NewLock(path, false)
+	assert.ErrorContains(t, err, "resource #2 has an empty url list")
+}
@@ -0,0 +1,330 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ociManifestType        = "application/vnd.oci.image.manifest.v1+json"
+	ociIndexType           = "application/vnd.oci.image.index.v1+json"
+	dockerManifestType     = "application/vnd.docker.distribution.manifest.v2+json"
+	dockerManifestListType = "application/vnd.docker.distribution.manifest.list.v2+json"
+	ociTitleAnnotation     = "org.opencontainers.image.title"
+	// Manifests larger than this are rejected.
+	ociMaxManifestSize = 4 * 1024 * 1024
+)
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Layers    []ociDescriptor `json:"layers"`
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+// ociFetcher downloads artifacts from OCI registries. Urls have the form
+// oci://registry/repository:tag or oci://registry/repository@sha256:digest.
+// The blob to download is the only layer of the manifest, or the one whose
+// title annotation matches the url fragment (e.g. #tool-linux-amd64).
+// Image indexes are resolved to the manifest of the current platform.
+type ociFetcher struct{}
+
+// ociReference is a parsed oci:// url.
+type ociReference struct {
+	registry   string
+	repository string
+	reference  string
+	title      string
+}
+
+func parseOciUrl(u *url.URL) (*ociReference, error) {
+	ref := &ociReference{registry: u.Host, title: u.Fragment}
+	repo := strings.TrimPrefix(u.Path, "/")
+	if i := strings.Index(repo, "@"); i >= 0 {
+		ref.repository, ref.reference = repo[:i], repo[i+1:]
+	} else if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		ref.repository, ref.reference = repo[:i], repo[i+1:]
+	} else {
+		ref.repository, ref.reference = repo, "latest"
+	}
+	if ref.registry == "" || ref.repository == "" || ref.reference == "" {
+		return nil, fmt.Errorf("invalid OCI url '%s' (expected oci://registry/repository:tag or oci://registry/repository@digest)", u)
+	}
+	return ref, nil
+}
+
+func (ociFetcher) fileName(u *url.URL) (string, error) {
+	ref, err := parseOciUrl(u)
+	if err != nil {
+		return "", err
+	}
+	return ref.fileName(), nil
+}
+
+// fileName returns the default name of the downloaded artifact.
+func (r *ociReference) fileName() string {
+	if r.title != "" {
+		return r.title
+	}
+	return path.Base(r.repository)
+}
+
+func (ociFetcher) Fetch(ctx context.Context, u *url.URL, headers map[string]string, fileName string) error {
+	ref, err := parseOciUrl(u)
+	if err != nil {
+		return err
+	}
+	expandedHeaders, err := expandHeaders(headers)
+	if err != nil {
+		return err
+	}
+	reg := &ociRegistry{ref: ref, headers: expandedHeaders}
+	manifest, err := reg.manifest(ctx, ref.reference)
+	if err != nil {
+		return err
+	}
+	if len(manifest.Manifests) > 0 {
+		digest := ""
+		for _, m := range manifest.Manifests {
+			if m.Platform != nil && m.Platform.OS == runtime.GOOS && m.Platform.Architecture == runtime.GOARCH {
+				digest = m.Digest
+				break
+			}
+		}
+		if digest == "" {
+			return fmt.Errorf("no manifest for platform %s/%s in '%s'", runtime.GOOS, runtime.GOARCH, u)
+		}
+		manifest, err = reg.manifest(ctx, digest)
+		if err != nil {
+			return err
+		}
+	}
+	var layer *ociDescriptor
+	for i, l := range manifest.Layers {
+		if ref.title == "" || l.Annotations[ociTitleAnnotation] == ref.title {
+			if layer != nil {
+				return fmt.Errorf("several layers found in '%s', select one with its title (e.g. '#%s')", u, l.Annotations[ociTitleAnnotation])
+			}
+			layer = &manifest.Layers[i]
+		}
+	}
+	if layer == nil {
+		return fmt.Errorf("no matching layer found in '%s'", u)
+	}
+	return reg.blob(ctx, *layer, fileName)
+}
+
+// ociRegistry implements the subset of the OCI distribution API needed to
+// pull blobs, including the registry token authentication.
+type ociRegistry struct {
+	ref *ociReference
+	// headers are the resource headers sent with the registry requests.
+	headers map[string]string
+	token   string
+}
+
+func (r *ociRegistry) baseUrl() string {
+	host := r.ref.registry
+	hostname := strings.Split(host, ":")[0]
+	if hostname == "localhost" || hostname == "127.0.0.1" {
+		return "http://" + host
+	}
+	return "https://" + host
+}
+
+// get sends an authenticated GET request to the registry, negotiating a
+// token when the registry requires one.
+func (r *ociRegistry) get(ctx context.Context, p string, accept string) (*http.Response, error) {
+	u := r.baseUrl() + "/v2/" + r.ref.repository + p
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range r.headers {
+			req.Header.Set(k, v)
+		}
+		req.Header.Set("Accept", accept)
+		if r.token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.token)
+		} else if cred, ok := credentials.ForUrl(req.URL); ok && cred.Username != "" {
+			req.SetBasicAuth(cred.Username, cred.Password)
+		}
+		res, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			challenge := res.Header.Get("WWW-Authenticate")
+			res.Body.Close()
+			err = r.authenticate(ctx, challenge)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return nil, fmt.Errorf("unexpected status '%s' for '%s'", res.Status, u)
+		}
+		return res, nil
+	}
+}
+
+// authenticate gets a registry token following the given
+// WWW-Authenticate challenge.
+func (r *ociRegistry) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return fmt.Errorf("unsupported registry authentication '%s'", scheme)
+	}
+	values := map[string]string{}
+	for _, p := range strings.Split(params, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		values[k] = strings.Trim(v, `"`)
+	}
+	realm, err := url.Parse(values["realm"])
+	if err != nil || values["realm"] == "" {
+		return fmt.Errorf("invalid registry authentication realm '%s'", values["realm"])
+	}
+	q := realm.Query()
+	if values["service"] != "" {
+		q.Set("service", values["service"])
+	}
+	scope := values["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", r.ref.repository)
+	}
+	q.Set("scope", scope)
+	realm.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	registryUrl, err := url.Parse(r.baseUrl())
+	if err != nil {
+		return err
+	}
+	if cred, ok := credentials.ForUrl(registryUrl); ok {
+		if cred.Token != "" {
+			req.SetBasicAuth("token", cred.Token)
+		} else {
+			req.SetBasicAuth(cred.Username, cred.Password)
+		}
+	}
+	log.Debug().Str("Registry", r.ref.registry).Msg("Requesting registry token")
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get registry token: %s", res.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&token)
+	if err != nil {
+		return fmt.Errorf("invalid registry token response: %s", err)
+	}
+	r.token = token.Token
+	if r.token == "" {
+		r.token = token.AccessToken
+	}
+	if r.token == "" {
+		return fmt.Errorf("empty registry token")
+	}
+	return nil
+}
+
+// manifest gets the manifest or index with the given tag or digest. When
+// a digest is given, the content of the manifest is verified against it.
+func (r *ociRegistry) manifest(ctx context.Context, reference string) (*ociManifest, error) {
+	accept := strings.Join([]string{ociManifestType, ociIndexType, dockerManifestType, dockerManifestListType}, ", ")
+	res, err := r.get(ctx, "/manifests/"+reference, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	content, err := io.ReadAll(io.LimitReader(res.Body, ociMaxManifestSize))
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(reference, ":") {
+		err = checkOciDigest(content, reference)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var manifest ociManifest
+	err = json.Unmarshal(content, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest '%s': %s", reference, err)
+	}
+	return &manifest, nil
+}
+
+// blob downloads the given blob to a file and verifies its digest.
+func (r *ociRegistry) blob(ctx context.Context, desc ociDescriptor, fileName string) error {
+	if !strings.HasPrefix(desc.Digest, "sha256:") {
+		return fmt.Errorf("unsupported digest '%s'", desc.Digest)
+	}
+	res, err := r.get(ctx, "/blobs/"+desc.Digest, "*/*")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	progress.setTotal(fileName, desc.Size)
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, h), res.Body)
+	if err != nil {
+		return err
+	}
+	if digest := "sha256:" + hex.EncodeToString(h.Sum(nil)); digest != desc.Digest {
+		return fmt.Errorf("OCI digest mismatch: got '%s' expected '%s'", digest, desc.Digest)
+	}
+	return file.Close()
+}
+
+func checkOciDigest(content []byte, digest string) error {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return fmt.Errorf("unsupported digest '%s'", digest)
+	}
+	sum := sha256.Sum256(content)
+	if computed := "sha256:" + hex.EncodeToString(sum[:]); computed != digest {
+		return fmt.Errorf("OCI digest mismatch: got '%s' expected '%s'", computed, digest)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ociDigest(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func ociRegistryHandler(t *testing.T, blobs map[string]string) http.HandlerFunc {
+	blob := `abcdef`
+	manifest := fmt.Sprintf(`{
+		"mediaType": "%s",
+		"layers": [
+			{"mediaType": "application/octet-stream", "digest": "%s", "size": 6, "annotations": {"%s": "tool"}},
+			{"mediaType": "application/octet-stream", "digest": "%s", "size": 3, "annotations": {"%s": "other"}}
+		]
+	}`, ociManifestType, ociDigest(blob), ociTitleAnnotation, ociDigest("xyz"), ociTitleAnnotation)
+	blobs[ociDigest(blob)] = blob
+	blobs[ociDigest("xyz")] = "xyz"
+	blobs["manifest"] = manifest
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			assert.Equal(t, "repository:org/tool:pull", r.URL.Query().Get("scope"))
+			_, err := w.Write([]byte(`{"token": "registry-token"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer registry-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="test"`, r.Host))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		content := ""
+		switch {
+		case r.URL.Path == "/v2/org/tool/manifests/v1" || r.URL.Path == "/v2/org/tool/manifests/"+ociDigest(manifest):
+			content = manifest
+		case strings.HasPrefix(r.URL.Path, "/v2/org/tool/blobs/"):
+			content = blobs[strings.TrimPrefix(r.URL.Path, "/v2/org/tool/blobs/")]
+		}
+		if content == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, err := w.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOciFetcher(t *testing.T) {
+	blobs := map[string]string{}
+	server := httptest.NewServer(ociRegistryHandler(t, blobs))
+	defer server.Close()
+	registry := strings.TrimPrefix(server.URL, "http://")
+	target := filepath.Join(tmpDir(t), "tool")
+
+	_, err := getUrl(fmt.Sprintf("oci://%s/org/tool:v1#tool", registry), target, nil, context.Background())
+	assert.Nil(t, err)
+	content, err := os.ReadFile(target)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(`abcdef`), content)
+
+	_, err = getUrl(fmt.Sprintf("oci://%s/org/tool@%s#other", registry, ociDigest(blobs["manifest"])), target, nil, context.Background())
+	assert.Nil(t, err)
+
+	_, err = getUrl(fmt.Sprintf("oci://%s/org/tool:v1", registry), target, nil, context.Background())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "several layers found")
+
+	_, err = getUrl(fmt.Sprintf("oci://%s/org/tool@%s#tool", registry, ociDigest("bogus")), target, nil, context.Background())
+	assert.NotNil(t, err)
+
+	// Tampered blob.
+	blobs[ociDigest("abcdef")] = "abcdeg"
+	_, err = getUrl(fmt.Sprintf("oci://%s/org/tool:v1#tool", registry), target, nil, context.Background())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "OCI digest mismatch")
+}
+
+func TestParseOciUrl(t *testing.T) {
+	tests := []struct {
+		url      string
+		valid    bool
+		expected ociReference
+		fileName string
+	}{
+		{"oci://ghcr.io/org/tool:v1", true, ociReference{"ghcr.io", "org/tool", "v1", ""}, "tool"},
+		{"oci://localhost:5000/tool", true, ociReference{"localhost:5000", "tool", "latest", ""}, "tool"},
+		{"oci://ghcr.io/org/tool@sha256:abc#tool.tgz", true, ociReference{"ghcr.io", "org/tool", "sha256:abc", "tool.tgz"}, "tool.tgz"},
+		{"oci://ghcr.io", false, ociReference{}, ""},
+	}
+	for _, data := range tests {
+		u, err := url.Parse(data.url)
+		assert.Nil(t, err)
+		ref, err := parseOciUrl(u)
+		assert.Equal(t, data.valid, err == nil)
+		if err == nil {
+			assert.Equal(t, data.expected, *ref)
+			assert.Equal(t, data.fileName, ref.fileName())
+		}
+	}
+}
+
+func TestOciFetcherHeaders(t *testing.T) {
+	registryHandler := ociRegistryHandler(t, map[string]string{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/token" && r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		registryHandler(w, r)
+	}))
+	defer server.Close()
+	registry := strings.TrimPrefix(server.URL, "http://")
+	target := filepath.Join(tmpDir(t), "tool")
+	u := fmt.Sprintf("oci://%s/org/tool:v1#tool", registry)
+
+	_, err := getUrl(u, target, nil, context.Background())
+	assert.ErrorContains(t, err, "403")
+	_, err = getUrl(u, target, map[string]string{"X-Token": "secret"}, context.Background())
+	assert.Nil(t, err)
+}
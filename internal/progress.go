@@ -0,0 +1,279 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/rs/zerolog/log"
+)
+
+// Progress displays the progress of the downloads. On a terminal, it
+// redraws a line per ongoing download and a summary line below the lines
+// of the finished downloads and of the logs. Otherwise it logs the state
+// of the ongoing downloads periodically.
+type Progress struct {
+	out      io.Writer
+	tty      bool
+	interval time.Duration
+	mu       sync.Mutex
+	tasks    []*progressTask
+	lines    int
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+type progressTask struct {
+	url      string
+	fileName string
+	bytes    int64
+	total    int64
+	started  time.Time
+	finished time.Time
+	failed   bool
+	// printed is set once the final state of the download is displayed.
+	printed bool
+}
+
+// progressMaxTasks is the maximum number of ongoing downloads displayed on
+// a terminal, so that the display fits on the screen.
+const progressMaxTasks = 10
+
+// progress is the display used by the downloads, none by default.
+var progress *Progress
+
+// SetProgress defines the display used by the downloads.
+func SetProgress(p *Progress) {
+	progress = p
+}
+
+// NewProgress creates a display writing to the given terminal, or logging
+// every interval when out is not a terminal.
+func NewProgress(out io.Writer, tty bool, interval time.Duration) *Progress {
+	return &Progress{out: out, tty: tty, interval: interval}
+}
+
+// Start refreshes the display until Stop is called.
+func (p *Progress) Start() {
+	p.stop = make(chan struct{})
+	p.stopped = make(chan struct{})
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.render()
+			case <-p.stop:
+				if p.tty {
+					p.render()
+				}
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops refreshing the display.
+func (p *Progress) Stop() {
+	close(p.stop)
+	<-p.stopped
+}
+
+// Write writes log lines to the output of the display. On a terminal, they
+// are written above the ongoing downloads, which are then redrawn.
+func (p *Progress) Write(b []byte) (int, error) {
+	if !p.tty {
+		return p.out.Write(b)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	n, err := p.out.Write(b)
+	p.draw()
+	return n, err
+}
+
+// start registers the download of the given url to the given file.
+func (p *Progress) start(u string, fileName string) *progressTask {
+	if p == nil {
+		return nil
+	}
+	t := &progressTask{url: u, fileName: fileName, started: time.Now()}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tasks = append(p.tasks, t)
+	return t
+}
+
+// finish marks the given download as finished.
+func (p *Progress) finish(t *progressTask, err error) {
+	if p == nil || t == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t.update()
+	t.finished = time.Now()
+	t.failed = err != nil
+}
+
+// setTotal records the expected size of the download to the given file.
+func (p *Progress) setTotal(fileName string, total int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, t := range p.tasks {
+		if t.fileName == fileName && t.finished.IsZero() {
+			t.total = total
+		}
+	}
+}
+
+// progressHandler returns a response handler that records the size of
+// the response before writing it to the given file.
+func progressHandler(fileName string) requests.ResponseHandler {
+	return requests.ChainHandlers(func(res *http.Response) error {
+		if res.ContentLength > 0 {
+			progress.setTotal(fileName, res.ContentLength)
+		}
+		return nil
+	}, requests.ToFile(fileName))
+}
+
+// update reads the number of bytes downloaded so far from the size of
+// the file being written.
+func (t *progressTask) update() {
+	if !t.finished.IsZero() {
+		return
+	}
+	if stat, err := os.Stat(t.fileName); err == nil && stat.Mode().IsRegular() {
+		t.bytes = stat.Size()
+	}
+}
+
+func (t *progressTask) String() string {
+	end := time.Now()
+	if !t.finished.IsZero() {
+		end = t.finished
+	}
+	elapsed := end.Sub(t.started).Seconds()
+	var b strings.Builder
+	b.WriteString(formatBytes(t.bytes))
+	if t.total > 0 {
+		fmt.Fprintf(&b, " / %s %3d%%", formatBytes(t.total), t.bytes*100/t.total)
+	}
+	if elapsed > 0 {
+		rate := float64(t.bytes) / elapsed
+		fmt.Fprintf(&b, " %s/s", formatBytes(int64(rate)))
+		if t.finished.IsZero() && t.total > t.bytes && rate > 0 {
+			eta := time.Duration(float64(t.total-t.bytes)/rate) * time.Second
+			fmt.Fprintf(&b, " ETA %s", eta.Round(time.Second))
+		}
+	}
+	switch {
+	case t.failed:
+		b.WriteString(" failed")
+	case !t.finished.IsZero():
+		b.WriteString(" done")
+	}
+	return b.String()
+}
+
+// summary returns the overall completion of the downloads.
+func (p *Progress) summary() string {
+	done := 0
+	var bytes, total int64
+	for _, t := range p.tasks {
+		if !t.finished.IsZero() {
+			done += 1
+		}
+		bytes += t.bytes
+		total += t.total
+	}
+	s := fmt.Sprintf("%d/%d downloads, %s", done, len(p.tasks), formatBytes(bytes))
+	if total > 0 {
+		s += fmt.Sprintf(" / %s", formatBytes(total))
+	}
+	return s
+}
+
+func (p *Progress) render() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, t := range p.tasks {
+		t.update()
+	}
+	if !p.tty {
+		for _, t := range p.tasks {
+			if t.finished.IsZero() {
+				log.Info().Str("URL", t.url).Str("Progress", t.String()).Msg("Downloading")
+			}
+		}
+		return
+	}
+	p.clear()
+	p.draw()
+}
+
+// clear erases the lines of the ongoing downloads and the summary line.
+func (p *Progress) clear() {
+	if p.lines > 0 {
+		fmt.Fprintf(p.out, "\x1b[%dA\r\x1b[J", p.lines)
+		p.lines = 0
+	}
+}
+
+// draw prints the final state of the downloads that finished since the
+// last call, which is kept on the screen, then the ongoing downloads and
+// the summary line, which are erased by the next call to clear.
+func (p *Progress) draw() {
+	ongoing := 0
+	for _, t := range p.tasks {
+		if !t.finished.IsZero() && !t.printed {
+			fmt.Fprintf(p.out, "%s  %s\n", t.url, t)
+			t.printed = true
+		}
+	}
+	for _, t := range p.tasks {
+		if !t.finished.IsZero() {
+			continue
+		}
+		if ongoing < progressMaxTasks {
+			fmt.Fprintf(p.out, "%s  %s\n", t.url, t)
+			p.lines++
+		}
+		ongoing++
+	}
+	if ongoing > progressMaxTasks {
+		fmt.Fprintf(p.out, "... and %d more\n", ongoing-progressMaxTasks)
+		p.lines++
+	}
+	fmt.Fprintf(p.out, "%s\n", p.summary())
+	p.lines++
+}
+
+// formatBytes returns a human readable size.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
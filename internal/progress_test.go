@@ -0,0 +1,86 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "12 B", formatBytes(12))
+	assert.Equal(t, "1.5 KiB", formatBytes(1536))
+	assert.Equal(t, "2.0 MiB", formatBytes(2*1024*1024))
+	assert.Equal(t, "3.0 GiB", formatBytes(3*1024*1024*1024))
+}
+
+func TestProgressTaskString(t *testing.T) {
+	started := time.Now().Add(-2 * time.Second)
+	task := &progressTask{bytes: 1024, total: 4096, started: started}
+	s := task.String()
+	assert.True(t, strings.HasPrefix(s, "1.0 KiB / 4.0 KiB  25%"), s)
+	assert.Contains(t, s, "ETA")
+	task.finished = started.Add(time.Second)
+	task.bytes = 4096
+	assert.Equal(t, "4.0 KiB / 4.0 KiB 100% 4.0 KiB/s done", task.String())
+}
+
+func TestProgressDownload(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`abcdef`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	var out bytes.Buffer
+	p := NewProgress(&out, true, time.Hour)
+	SetProgress(p)
+	t.Cleanup(func() { SetProgress(nil) })
+	p.Start()
+	u := fmt.Sprintf("http://localhost:%d/test.html", port)
+	_, err := getUrl(u, filepath.Join(tmpDir(t), "test.html"), nil, context.Background())
+	p.Stop()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(p.tasks))
+	assert.Equal(t, int64(6), p.tasks[0].total)
+	assert.Equal(t, int64(6), p.tasks[0].bytes)
+	assert.Contains(t, out.String(), u+"  6 B / 6 B 100%")
+	assert.Contains(t, out.String(), "1/1 downloads, 6 B / 6 B")
+}
+
+func TestProgressTerminal(t *testing.T) {
+	var out bytes.Buffer
+	p := NewProgress(&out, true, time.Hour)
+	var tasks []*progressTask
+	for i := 0; i < progressMaxTasks+2; i++ {
+		tasks = append(tasks, p.start(fmt.Sprintf("http://localhost:123456/%d.html", i), filepath.Join(tmpDir(t), "missing")))
+	}
+	p.finish(tasks[0], nil)
+	p.render()
+	// The finished download is printed once and the ongoing ones that do
+	// not fit are summarized.
+	assert.Equal(t, 1, strings.Count(out.String(), "http://localhost:123456/0.html"))
+	assert.Contains(t, out.String(), "... and 1 more\n")
+	assert.Contains(t, out.String(), fmt.Sprintf("1/%d downloads", progressMaxTasks+2))
+	assert.Equal(t, progressMaxTasks+2, p.lines)
+
+	out.Reset()
+	_, err := p.Write([]byte("log line\n"))
+	assert.Nil(t, err)
+	// The log line replaces the ongoing downloads, which are redrawn
+	// below it.
+	assert.True(t, strings.HasPrefix(out.String(), fmt.Sprintf("\x1b[%dA\r\x1b[Jlog line\n", progressMaxTasks+2)), out.String())
+	assert.NotContains(t, out.String(), "http://localhost:123456/0.html")
+	assert.Contains(t, out.String(), "http://localhost:123456/1.html")
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+//go:build linux
+
+package internal
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// FICLONE from linux/fs.h.
+const ficlone = 0x40049409
+
+// reflinkFile creates dst as a copy-on-write clone of src.
+func reflinkFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd())
+	err = out.Close()
+	if errno != 0 {
+		os.Remove(dst)
+		return errno
+	}
+	if err != nil {
+		return errors.Join(err, os.Remove(dst))
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+//go:build !linux
+
+package internal
+
+import "errors"
+
+// reflinkFile is only implemented on Linux.
+func reflinkFile(src string, dst string) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Kinds of errors reported in machine-readable output.
+const (
+	KindDownload  = "download"
+	KindIntegrity = "integrity"
+	KindSignature = "signature"
+	KindMissing   = "missing"
+	KindCanceled  = "canceled"
+	KindOther     = "other"
+)
+
+// kindError attaches a kind to an error without changing its message.
+type kindError struct {
+	kind string
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+func withKind(kind string, err error) error {
+	return &kindError{kind: kind, err: err}
+}
+
+// ErrorKind returns the kind of the given error, or an empty string if
+// there is no error.
+func ErrorKind(err error) string {
+	if err == nil {
+		return ""
+	}
+	var kErr *kindError
+	if errors.As(err, &kErr) {
+		return kErr.kind
+	}
+	if errors.Is(err, context.Canceled) {
+		return KindCanceled
+	}
+	return KindOther
+}
+
+// installedSize returns the size of the given file or, for a directory,
+// of all the files it contains.
+func installedSize(path string) (int64, error) {
+	var size int64
+	stat, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if !stat.IsDir() {
+		return stat.Size(), nil
+	}
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// ResourceReport is the machine-readable description of a resource and
+// of the outcome of an operation on it.
+type ResourceReport struct {
+	Resource   string   `json:"resource"`
+	Name       string   `json:"name"`
+	Version    string   `json:"version,omitempty"`
+	License    string   `json:"license,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Url        string   `json:"url,omitempty"`
+	Path       string   `json:"path,omitempty"`
+	Bytes      int64    `json:"bytes"`
+	DurationMs int64    `json:"durationMs"`
+	Status     string   `json:"status"`
+	Error      string   `json:"error,omitempty"`
+	ErrorKind  string   `json:"errorKind,omitempty"`
+}
+
+// Report is the machine-readable summary of a command.
+type Report struct {
+	Command   string           `json:"command"`
+	Status    string           `json:"status"`
+	Error     string           `json:"error,omitempty"`
+	Resources []ResourceReport `json:"resources"`
+}
+
+// NewReport summarizes the given results of a command that returned the
+// given error.
+func NewReport(command string, results []ResourceResult, err error) *Report {
+	report := &Report{Command: command, Status: "ok", Resources: []ResourceReport{}}
+	if err != nil {
+		report.Status = "failed"
+		report.Error = err.Error()
+	}
+	for _, result := range results {
+		r := result.Resource
+		rr := ResourceReport{
+			Resource: r.firstUrl(),
+			Name:     r.name(),
+			Status:   "ok",
+			Version:  r.Version,
+			License:  r.License,
+			Tags:     r.Tags,
+			Url:      result.Url,
+			Path:     result.Path,
+			Bytes:    result.Bytes,
+		}
+		if !result.Finished.IsZero() {
+			rr.DurationMs = result.Finished.Sub(result.Started).Milliseconds()
+		}
+		if result.Err != nil {
+			rr.Status = "failed"
+			rr.Error = result.Err.Error()
+			rr.ErrorKind = ErrorKind(result.Err)
+		}
+		report.Resources = append(report.Resources, rr)
+	}
+	return report
+}
+
+// Write writes the report as indented JSON.
+func (r *Report) Write(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(r)
+}
+
+// Results returns the results describing the resources that have all the
+// given tags and none of the given notags.
+func (l *Lock) Results(tags []string, notags []string) []ResourceResult {
+	results := []ResourceResult{}
+	for _, r := range l.filterResources(tags, notags) {
+		results = append(results, ResourceResult{Resource: r})
+	}
+	return results
+}
+
+// Resource returns the resource with the given url.
+func (l *Lock) Resource(u string) (Resource, bool) {
+	for _, r := range l.resources() {
+		if r.Contains(u) {
+			return r, true
+		}
+	}
+	return Resource{}, false
+}
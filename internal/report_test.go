@@ -0,0 +1,178 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorKind(t *testing.T) {
+	assert.Equal(t, "", ErrorKind(nil))
+	assert.Equal(t, KindOther, ErrorKind(fmt.Errorf("boom")))
+	assert.Equal(t, KindCanceled, ErrorKind(fmt.Errorf("wrapped: %w", context.Canceled)))
+	err := withKind(KindIntegrity, fmt.Errorf("integrity mismatch"))
+	assert.Equal(t, "integrity mismatch", err.Error())
+	assert.Equal(t, KindIntegrity, ErrorKind(err))
+}
+
+func TestInstalledSize(t *testing.T) {
+	dir := tmpDir(t)
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "a"), []byte(`abc`), 0644))
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "sub", "b"), []byte(`defg`), 0644))
+	size, err := installedSize(filepath.Join(dir, "a"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), size)
+	size, err = installedSize(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7), size)
+}
+
+func TestDownloadReport(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`abcdef`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	path := tmpFile(t, fmt.Sprintf(`
+		[[Resource]]
+		Urls = ['http://localhost:%d/test.html']
+		Integrity = 'sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE='`, port))
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	results, err := lock.Download(tmpDir(t), []string{}, []string{}, "", nil)
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	err = NewReport("download", results, err).Write(&buf)
+	assert.Nil(t, err)
+	var report Report
+	err = json.Unmarshal(buf.Bytes(), &report)
+	assert.Nil(t, err)
+	assert.Equal(t, "download", report.Command)
+	assert.Equal(t, "ok", report.Status)
+	assert.Equal(t, 1, len(report.Resources))
+	r := report.Resources[0]
+	assert.Equal(t, "test.html", r.Name)
+	assert.Equal(t, "ok", r.Status)
+	assert.Equal(t, fmt.Sprintf("http://localhost:%d/test.html", port), r.Url)
+	assert.Equal(t, int64(6), r.Bytes)
+}
+
+func TestDownloadReportFailure(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`abcdef`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	path := tmpFile(t, fmt.Sprintf(`
+		[[Resource]]
+		Urls = ['http://localhost:%d/test.html']
+		Integrity = 'sha256-asdasdasd'`, port))
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	results, err := lock.Download(tmpDir(t), []string{}, []string{}, "", nil)
+	assert.NotNil(t, err)
+	report := NewReport("download", results, err)
+	assert.Equal(t, "failed", report.Status)
+	assert.Equal(t, 1, len(report.Resources))
+	assert.Equal(t, "failed", report.Resources[0].Status)
+	assert.Equal(t, KindIntegrity, report.Resources[0].ErrorKind)
+
+	// The size and duration are always present, even when zero.
+	var buf bytes.Buffer
+	err = report.Write(&buf)
+	assert.Nil(t, err)
+	var fields struct {
+		Resources []map[string]any `json:"resources"`
+	}
+	err = json.Unmarshal(buf.Bytes(), &fields)
+	assert.Nil(t, err)
+	assert.Contains(t, fields.Resources[0], "bytes")
+	assert.Contains(t, fields.Resources[0], "durationMs")
+}
+
+func TestDownloadReportCanceled(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/test.html" {
+			// Hold the download until the failure of the other resource
+			// cancels it.
+			<-r.Context().Done()
+			return
+		}
+		_, err := w.Write([]byte(`abcdef`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	path := tmpFile(t, fmt.Sprintf(`
+		[[Resource]]
+		Urls = ['http://localhost:%d/test.html']
+		Integrity = 'sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE='
+
+		[[Resource]]
+		Urls = ['http://localhost:%d/other.html']
+		Integrity = 'sha256-asdasdasd'`, port, port))
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	results, err := lock.Download(tmpDir(t), []string{}, []string{}, "", nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, len(results))
+
+	report := NewReport("download", results, err)
+	assert.Equal(t, "failed", report.Status)
+	byName := map[string]ResourceReport{}
+	for _, r := range report.Resources {
+		byName[r.Name] = r
+	}
+	canceled := byName["test.html"]
+	assert.Equal(t, "failed", canceled.Status)
+	assert.Equal(t, KindCanceled, canceled.ErrorKind)
+	failed := byName["other.html"]
+	assert.Equal(t, "failed", failed.Status)
+	assert.Equal(t, KindIntegrity, failed.ErrorKind)
+}
+
+func TestAddErrorKind(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	_, err := NewResourceFromUrl([]string{fmt.Sprintf("http://localhost:%d/test.html", port)}, RecommendedAlgo, ResourceOptions{})
+	assert.ErrorContains(t, err, "failed to get url")
+	assert.Equal(t, KindDownload, ErrorKind(err))
+}
+
+func TestVerifyReport(t *testing.T) {
+	path := tmpFile(t, `
+		[[Resource]]
+		Urls = ['http://localhost:123456/test.html']
+		Integrity = 'sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE='`)
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	results, err := lock.Verify(tmpDir(t), []string{}, []string{})
+	assert.NotNil(t, err)
+	report := NewReport("verify", results, err)
+	assert.Equal(t, 1, len(report.Resources))
+	assert.Equal(t, KindMissing, report.Resources[0].ErrorKind)
+	assert.Equal(t, "http://localhost:123456/test.html", report.Resources[0].Resource)
+}
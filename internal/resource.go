@@ -7,6 +7,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
@@ -14,111 +15,366 @@ import (
 	"path/filepath"
 
 	"github.com/rs/zerolog/log"
-
-	"github.com/carlmjohnson/requests"
 )
 
 // Resource represents an external resource to be downloaded.
 type Resource struct {
-	Urls      []string
-	Integrity string
-	Tags      []string `toml:",omitempty"`
-	Filename  string   `toml:",omitempty"`
+	Urls      []string          `yaml:"Urls"`
+	Integrity string            `yaml:"Integrity"`
+	Tags      []string          `toml:",omitempty" json:",omitempty" yaml:"Tags,omitempty"`
+	Filename  string            `toml:",omitempty" json:",omitempty" yaml:"Filename,omitempty"`
+	Mode      string            `toml:",omitempty" json:",omitempty" yaml:"Mode,omitempty"`
+	Headers   map[string]string `toml:",omitempty" json:",omitempty" yaml:"Headers,omitempty"`
+	// Extract defines whether the resource is an archive to be extracted
+	// in a directory whose content is checked against TreeIntegrity.
+	Extract       bool   `toml:",omitempty" json:",omitempty" yaml:"Extract,omitempty"`
+	TreeIntegrity string `toml:",omitempty" json:",omitempty" yaml:"TreeIntegrity,omitempty"`
+	// Signature is the url of a detached signature of the resource,
+	// verified with PublicKey or with a key of the trusted keyring.
+	Signature string `toml:",omitempty" json:",omitempty" yaml:"Signature,omitempty"`
+	PublicKey string `toml:",omitempty" json:",omitempty" yaml:"PublicKey,omitempty"`
+	// ChecksumsUrl is the url of the checksum manifest the resource
+	// was verified against when added.
+	ChecksumsUrl string `toml:",omitempty" json:",omitempty" yaml:"ChecksumsUrl,omitempty"`
+	// Optional metadata describing the resource, reported by the list
+	// command and in the SBOM.
+	Name        string `toml:",omitempty" json:",omitempty" yaml:"Name,omitempty"`
+	Version     string `toml:",omitempty" json:",omitempty" yaml:"Version,omitempty"`
+	License     string `toml:",omitempty" json:",omitempty" yaml:"License,omitempty"`
+	Supplier    string `toml:",omitempty" json:",omitempty" yaml:"Supplier,omitempty"`
+	Homepage    string `toml:",omitempty" json:",omitempty" yaml:"Homepage,omitempty"`
+	Description string `toml:",omitempty" json:",omitempty" yaml:"Description,omitempty"`
+}
+
+// ResourceOptions holds the optional settings of a new resource.
+type ResourceOptions struct {
+	Tags         []string
+	Filename     string
+	Mode         string
+	Headers      map[string]string
+	Extract      bool
+	Signature    string
+	PublicKey    string
+	ChecksumsUrl string
+	Name         string
+	Version      string
+	License      string
+	Supplier     string
+	Homepage     string
+	Description  string
 }
 
-func NewResourceFromUrl(urls []string, algo string, tags []string, filename string) (*Resource, error) {
+func NewResourceFromUrl(urls []string, algo string, opts ResourceOptions) (*Resource, error) {
 	if len(urls) < 1 {
 		return nil, fmt.Errorf("empty url list")
 	}
+	for _, u := range urls {
+		parsedUrl, err := url.Parse(u)
+		if err == nil && parsedUrl.User != nil {
+			return nil, fmt.Errorf("url '%s' must not embed credentials, use the credentials file, netrc or environment instead", parsedUrl.Redacted())
+		}
+	}
+	mode, err := normalizeMode(opts.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PublicKey != "" && opts.Signature == "" {
+		return nil, fmt.Errorf("a public key requires a signature url")
+	}
 	url := urls[0]
 	ctx := context.Background()
-	path, err := GetUrltoTempFile(url, ctx)
+	path, err := GetUrltoTempFile(url, opts.Headers, ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get url: %s", err)
+		return nil, fmt.Errorf("failed to get url: %w", err)
 	}
 	defer os.Remove(path)
 	integrity, err := getIntegrityFromFile(path, algo)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compute ressource integrity: %s", err)
 	}
-	return &Resource{Urls: urls, Integrity: integrity, Tags: tags, Filename: filename}, nil
+	r := &Resource{
+		Urls:         urls,
+		Integrity:    integrity,
+		Tags:         opts.Tags,
+		Filename:     opts.Filename,
+		Mode:         mode,
+		Headers:      opts.Headers,
+		Extract:      opts.Extract,
+		Signature:    opts.Signature,
+		PublicKey:    opts.PublicKey,
+		ChecksumsUrl: opts.ChecksumsUrl,
+		Name:         opts.Name,
+		Version:      opts.Version,
+		License:      opts.License,
+		Supplier:     opts.Supplier,
+		Homepage:     opts.Homepage,
+		Description:  opts.Description,
+	}
+	if r.ChecksumsUrl != "" {
+		err = r.verifyChecksums(path, url, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.Signature != "" {
+		err = r.verifySignature(path, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.Extract {
+		if !isArchive(path) {
+			return nil, fmt.Errorf("'%s' is not a tar archive and cannot be extracted", url)
+		}
+		r.TreeIntegrity, err = getTreeIntegrityFromArchive(path, algo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute ressource tree integrity: %s", err)
+		}
+	}
+	return r, nil
+}
+
+// normalizeMode validates a resource mode and returns it in its
+// canonical 4 digits octal form.
+func normalizeMode(mode string) (string, error) {
+	fileMode, err := strToFileMode(mode)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a valid permission definition", mode)
+	}
+	if fileMode == NoFileMode {
+		return mode, nil
+	}
+	return fmt.Sprintf("%04o", fileMode.Perm()), nil
 }
 
 // getUrl downloads the given resource and returns the path to it.
-func getUrl(u string, fileName string, ctx context.Context) (string, error) {
-	_, err := url.Parse(u)
+func getUrl(u string, fileName string, headers map[string]string, ctx context.Context) (string, error) {
+	parsedUrl, err := url.Parse(u)
 	if err != nil {
 		return "", fmt.Errorf("invalid url '%s': %s", u, err)
 	}
 	log.Debug().Str("URL", u).Msg("Downloading")
-	err = requests.
-		URL(u).
-		Header("Accept", "*/*").
-		ToFile(fileName).
-		Fetch(ctx)
+	task := progress.start(u, fileName)
+	fetcher, err := getFetcher(parsedUrl)
+	if err == nil {
+		err = fetcher.Fetch(ctx, parsedUrl, headers, fileName)
+	}
+	progress.finish(task, err)
 	if err != nil {
-		return "", fmt.Errorf("failed to download '%s': %s", u, err)
+		kind := KindDownload
+		if errors.Is(err, context.Canceled) {
+			kind = KindCanceled
+		}
+		return "", withKind(kind, fmt.Errorf("failed to download '%s': %w", u, err))
 	}
 	log.Debug().Str("URL", u).Msg("Downloaded")
 	return fileName, nil
 }
 
 // GetUrlToDir downloads the given resource to the given directory and returns the path to it.
-func GetUrlToDir(u string, targetDir string, ctx context.Context) (string, error) {
+func GetUrlToDir(u string, targetDir string, headers map[string]string, ctx context.Context) (string, error) {
 	// create temporary name in the target directory.
 	h := sha256.New()
 	h.Write([]byte(u))
 	fileName := filepath.Join(targetDir, fmt.Sprintf(".%s", hex.EncodeToString(h.Sum(nil))))
-	return getUrl(u, fileName, ctx)
+	return getUrl(u, fileName, headers, ctx)
 }
 
 // GetUrlWithDir downloads the given resource to a temporary file and returns the path to it.
-func GetUrltoTempFile(u string, ctx context.Context) (string, error) {
+func GetUrltoTempFile(u string, headers map[string]string, ctx context.Context) (string, error) {
 	file, err := os.CreateTemp("", "prefix")
 	if err != nil {
 		log.Fatal().Err(err)
 	}
 	fileName := file.Name()
-	return getUrl(u, fileName, ctx)
+	return getUrl(u, fileName, headers, ctx)
 }
 
-func (l *Resource) Download(dir string, mode os.FileMode, ctx context.Context) error {
-	ok := false
+// Download gets the resource and moves it to the destination directory.
+// It returns the url the resource was downloaded from.
+func (l *Resource) Download(dir string, mode os.FileMode, store *Store, ctx context.Context) (string, error) {
+	used := ""
 	algo, err := getAlgoFromIntegrity(l.Integrity)
 	if err != nil {
-		return err
+		return "", err
+	}
+	// A mode defined on the resource takes precedence over the global one.
+	if l.Extract {
+		mode = NoFileMode
+	} else if l.Mode != "" {
+		mode, err = strToFileMode(l.Mode)
+		if err != nil {
+			return "", fmt.Errorf("'%s' is not a valid permission definition", l.Mode)
+		}
+	}
+	if store != nil {
+		return l.downloadFromStore(dir, mode, algo, store, ctx)
 	}
 	for _, u := range l.Urls {
 		// Download file in the target directory so that the call to
 		// os.Rename is atomic.
-		lpath, err := GetUrlToDir(u, dir, ctx)
+		var lpath string
+		lpath, err = GetUrlToDir(u, dir, l.Headers, ctx)
 		if err != nil {
 			break
 		}
 		err = checkIntegrityFromFile(lpath, algo, l.Integrity, u)
 		if err != nil {
-			return err
+			return "", err
+		}
+		err = l.verifySignature(lpath, ctx)
+		if err != nil {
+			os.Remove(lpath)
+			return "", err
 		}
 
-		localName := ""
-		if l.Filename != "" {
-			localName = l.Filename
-		} else {
-			localName = path.Base(u)
+		resPath := filepath.Join(dir, l.localName(u))
+		if l.Extract {
+			err = l.extract(lpath, resPath, algo)
+			os.Remove(lpath)
+			if err != nil {
+				return "", err
+			}
+			used = u
+			continue
 		}
-		resPath := filepath.Join(dir, localName)
 		err = os.Rename(lpath, resPath)
 		if err != nil {
-			return err
+			return "", err
 		}
-		if mode != NoFileMode {
-			os.Chmod(resPath, mode.Perm())
+		err = setFileMode(resPath, mode)
+		if err != nil {
+			return "", err
 		}
-		ok = true
+		used = u
+	}
+	if used == "" {
+		return "", err
 	}
-	if !ok {
+	return used, nil
+}
+
+// downloadFromStore fetches the resource into the store and makes it
+// available in the target directory.
+func (l *Resource) downloadFromStore(dir string, mode os.FileMode, algo string, store *Store, ctx context.Context) (string, error) {
+	var err error
+	for _, u := range l.Urls {
+		var objPath string
+		objPath, err = store.fetch(u, algo, l.Integrity, l.Headers, ctx)
+		if err != nil {
+			continue
+		}
+		err = l.verifySignature(objPath, ctx)
+		if err != nil {
+			return "", err
+		}
+		resPath := filepath.Join(dir, l.localName(u))
+		if l.Extract {
+			return u, l.extract(objPath, resPath, algo)
+		}
+		return u, store.materialise(objPath, resPath, mode)
+	}
+	return "", err
+}
+
+// extract extracts the resource archive to the given directory, replacing
+// it if it exists, once its content has been verified.
+func (l *Resource) extract(archive string, resPath string, algo string) error {
+	if l.TreeIntegrity == "" {
+		return fmt.Errorf("missing tree integrity for '%s'", resPath)
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(resPath), fmt.Sprintf(".%s.tmp", filepath.Base(resPath)))
+	if err != nil {
 		return err
 	}
+	defer os.RemoveAll(tmpDir)
+	err = extractArchive(archive, tmpDir)
+	if err != nil {
+		return fmt.Errorf("failed to extract '%s': %s", resPath, err)
+	}
+	err = checkIntegrityFromTree(tmpDir, algo, l.TreeIntegrity)
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(resPath)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpDir, resPath)
+}
+
+// Verify checks that the resource downloaded in the given directory
+// matches its integrity.
+func (l *Resource) Verify(dir string) error {
+	algo, err := getAlgoFromIntegrity(l.Integrity)
+	if err != nil {
+		return err
+	}
+	for _, u := range l.Urls {
+		resPath := filepath.Join(dir, l.localName(u))
+		if _, err := os.Stat(resPath); err != nil {
+			continue
+		}
+		if l.Extract {
+			return checkIntegrityFromTree(resPath, algo, l.TreeIntegrity)
+		}
+		return checkIntegrityFromFile(resPath, algo, l.Integrity, resPath)
+	}
+	return withKind(KindMissing, fmt.Errorf("'%s' not found in '%s'", l.localName(l.Urls[0]), dir))
+}
+
+// localName returns the name of the file the resource is stored in
+// when downloaded from the given url.
+func (l *Resource) localName(u string) string {
+	if l.Filename != "" {
+		return l.Filename
+	}
+	if l.Extract {
+		return extractedName(l.urlName(u))
+	}
+	return l.urlName(u)
+}
+
+// firstUrl returns the main url of the resource, or an empty string if it
+// has none.
+func (l *Resource) firstUrl() string {
+	if len(l.Urls) == 0 {
+		return ""
+	}
+	return l.Urls[0]
+}
+
+// name returns the name of the resource, which defaults to the name of
+// its first url.
+func (l *Resource) name() string {
+	if l.Name != "" || len(l.Urls) == 0 {
+		return l.Name
+	}
+	return l.localName(l.Urls[0])
+}
+
+// urlName returns the file name defined by the given url.
+func (l *Resource) urlName(u string) string {
+	if parsedUrl, err := url.Parse(u); err == nil {
+		if fetcher, err := getFetcher(parsedUrl); err == nil {
+			if namer, ok := fetcher.(fileNamer); ok {
+				if name, err := namer.fileName(parsedUrl); err == nil {
+					return name
+				}
+			}
+		}
+	}
+	return path.Base(u)
+}
+
+func setFileMode(path string, mode os.FileMode) error {
+	if mode == NoFileMode {
+		return nil
+	}
+	err := os.Chmod(path, mode.Perm())
+	if err != nil {
+		return fmt.Errorf("failed to set permissions of '%s': %s", path, err)
+	}
 	return nil
 }
 
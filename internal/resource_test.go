@@ -40,7 +40,7 @@ func TestNewResourceFromUrl(t *testing.T) {
 	}
 
 	for _, data := range tests {
-		resource, err := NewResourceFromUrl(data.urls, algo, []string{}, "")
+		resource, err := NewResourceFromUrl(data.urls, algo, ResourceOptions{Tags: []string{}})
 		assert.Equal(t, data.valid, err == nil)
 		if err != nil {
 			assert.Contains(t, err.Error(), data.errorContains)
@@ -49,3 +49,31 @@ func TestNewResourceFromUrl(t *testing.T) {
 		}
 	}
 }
+
+func TestNewResourceFromUrlMode(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`abcdef`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	url := fmt.Sprintf("http://localhost:%d/test.sh", port)
+	resource, err := NewResourceFromUrl([]string{url}, "sha256", ResourceOptions{Mode: "755"})
+	assert.Nil(t, err)
+	assert.Equal(t, "0755", resource.Mode)
+	_, err = NewResourceFromUrl([]string{url}, "sha256", ResourceOptions{Mode: "bogus"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not a valid permission definition")
+}
+
+func TestResourceName(t *testing.T) {
+	r := Resource{Urls: []string{"http://localhost:123456/a/test.html"}}
+	assert.Equal(t, "test.html", r.name())
+	r.Name = "test"
+	assert.Equal(t, "test", r.name())
+	r = Resource{}
+	assert.Equal(t, "", r.name())
+	assert.Equal(t, "", r.firstUrl())
+}
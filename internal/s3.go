@@ -0,0 +1,182 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+)
+
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// s3Fetcher downloads s3://bucket/key resources from AWS S3 or any
+// S3-compatible object store. It is configured with the standard AWS
+// environment variables:
+//   - AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL: endpoint of an S3-compatible
+//     store, accessed with path-style urls,
+//   - AWS_REGION or AWS_DEFAULT_REGION: region of the bucket (default: us-east-1),
+//   - AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN:
+//     credentials used to sign the requests.
+//
+// When no AWS credentials are defined, the credentials matching the
+// endpoint host are used (username as access key, password as secret key)
+// and the request is anonymous if there are none.
+type s3Fetcher struct{}
+
+func (s3Fetcher) Fetch(ctx context.Context, u *url.URL, headers map[string]string, fileName string) error {
+	bucket := u.Host
+	key := strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return fmt.Errorf("invalid S3 url '%s' (expected s3://bucket/key)", u)
+	}
+	region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	// The key is encoded as in the canonical request so that the path of
+	// the request is the signed one.
+	var endpoint *url.URL
+	var err error
+	if e := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); e != "" {
+		endpoint, err = url.Parse(strings.TrimSuffix(e, "/") + "/" + s3UriEncode(bucket) + "/" + s3EscapePath(key))
+	} else {
+		endpoint, err = url.Parse(fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, s3EscapePath(key)))
+	}
+	if err != nil {
+		return err
+	}
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	sessionToken := os.Getenv("AWS_SESSION_TOKEN")
+	if accessKey == "" {
+		if cred, ok := credentials.ForUrl(endpoint); ok {
+			accessKey = cred.Username
+			secretKey = cred.Password
+		}
+	}
+	expandedHeaders, err := expandHeaders(headers)
+	if err != nil {
+		return err
+	}
+	rb := requests.
+		URL(endpoint.String()).
+		Client(httpClient)
+	for k, v := range expandedHeaders {
+		rb = rb.Header(k, v)
+	}
+	if accessKey != "" {
+		signed := map[string]string{
+			"x-amz-content-sha256": s3UnsignedPayload,
+			"x-amz-date":           time.Now().UTC().Format("20060102T150405Z"),
+		}
+		if sessionToken != "" {
+			signed["x-amz-security-token"] = sessionToken
+		}
+		for k, v := range signed {
+			rb = rb.Header(k, v)
+		}
+		rb = rb.Header("Authorization", signS3Request("GET", endpoint, signed, s3UnsignedPayload, accessKey, secretKey, region))
+	}
+	return rb.
+		Handle(progressHandler(fileName)).
+		Fetch(ctx)
+}
+
+// signS3Request computes the AWS signature version 4 Authorization header
+// of a request. headers are the signed headers other than Host and must
+// include x-amz-date.
+func signS3Request(method string, u *url.URL, headers map[string]string, payloadHash string, accessKey string, secretKey string, region string) string {
+	amzDate := headers["x-amz-date"]
+	date := amzDate[:8]
+	all := map[string]string{"host": u.Host}
+	for k, v := range headers {
+		all[strings.ToLower(k)] = strings.TrimSpace(v)
+	}
+	names := make([]string, 0, len(all))
+	for k := range all {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + all[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		method,
+		s3EscapePath(u.Path),
+		u.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, region)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature)
+}
+
+// s3EscapePath URI-encodes each segment of the given decoded path as
+// required by the canonical request.
+func s3EscapePath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = s3UriEncode(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3UriEncode percent-encodes all the bytes of the given string but the
+// unreserved characters, as specified by AWS signature version 4.
+func s3UriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// firstEnv returns the value of the first defined environment variable.
+func firstEnv(names ...string) string {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+type sbomGenerator func(l *Lock) (any, error)
+
+var sbomFormats = map[string]sbomGenerator{
+	"cyclonedx-json": cyclonedxSbom,
+	"spdx-json":      spdxSbom,
+}
+
+// SbomFormats returns the names of the supported SBOM formats.
+func SbomFormats() []string {
+	formats := make([]string, 0, len(sbomFormats))
+	for f := range sbomFormats {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// WriteSbom writes a software bill of materials listing the resources of
+// this lock file in the given format.
+func (l *Lock) WriteSbom(w io.Writer, format string) error {
+	generator, ok := sbomFormats[format]
+	if !ok {
+		return fmt.Errorf("unknown SBOM format '%s' (available formats: %s)", format, strings.Join(SbomFormats(), ", "))
+	}
+	sbom, err := generator(l)
+	if err != nil {
+		return err
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(sbom)
+}
+
+// integrityDigest decodes the given SRI into its algorithm and its
+// hexadecimal digest.
+func integrityDigest(integrity string) (string, string, error) {
+	algo, err := getAlgoFromIntegrity(integrity)
+	if err != nil {
+		return "", "", err
+	}
+	digest, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(integrity, algo+"-"))
+	if err != nil {
+		return "", "", fmt.Errorf("invalid SRI '%s': %s", integrity, err)
+	}
+	return algo, hex.EncodeToString(digest), nil
+}
+
+func randomUuid() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+type cyclonedxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cyclonedxReference struct {
+	Type string `json:"type"`
+	Url  string `json:"url"`
+}
+
+type cyclonedxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cyclonedxLicense struct {
+	Expression string `json:"expression"`
+}
+
+type cyclonedxSupplier struct {
+	Name string `json:"name"`
+}
+
+type cyclonedxComponent struct {
+	Type               string               `json:"type"`
+	BomRef             string               `json:"bom-ref"`
+	Name               string               `json:"name"`
+	Version            string               `json:"version,omitempty"`
+	Description        string               `json:"description,omitempty"`
+	Supplier           *cyclonedxSupplier   `json:"supplier,omitempty"`
+	Hashes             []cyclonedxHash      `json:"hashes"`
+	Licenses           []cyclonedxLicense   `json:"licenses,omitempty"`
+	ExternalReferences []cyclonedxReference `json:"externalReferences"`
+	Properties         []cyclonedxProperty  `json:"properties,omitempty"`
+}
+
+type cyclonedxTool struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type cyclonedxMetadata struct {
+	Timestamp string `json:"timestamp"`
+	Tools     struct {
+		Components []cyclonedxTool `json:"components"`
+	} `json:"tools"`
+}
+
+type cyclonedxBom struct {
+	BomFormat    string               `json:"bomFormat"`
+	SpecVersion  string               `json:"specVersion"`
+	SerialNumber string               `json:"serialNumber"`
+	Version      int                  `json:"version"`
+	Metadata     cyclonedxMetadata    `json:"metadata"`
+	Components   []cyclonedxComponent `json:"components"`
+}
+
+// cyclonedxSbom generates a CycloneDX 1.5 SBOM.
+func cyclonedxSbom(l *Lock) (any, error) {
+	bom := cyclonedxBom{
+		BomFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + randomUuid(),
+		Version:      1,
+		Components:   []cyclonedxComponent{},
+	}
+	bom.Metadata.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	bom.Metadata.Tools.Components = []cyclonedxTool{{Type: "application", Name: "grabit", Version: Version}}
+	for i, r := range l.resources() {
+		algo, digest, err := integrityDigest(r.Integrity)
+		if err != nil {
+			return nil, err
+		}
+		c := cyclonedxComponent{
+			Type:        "file",
+			BomRef:      fmt.Sprintf("resource-%d", i+1),
+			Name:        r.name(),
+			Version:     r.Version,
+			Description: r.Description,
+			Hashes:      []cyclonedxHash{{Alg: "SHA-" + strings.TrimPrefix(algo, "sha"), Content: digest}},
+		}
+		if r.Supplier != "" {
+			c.Supplier = &cyclonedxSupplier{Name: r.Supplier}
+		}
+		if r.License != "" {
+			c.Licenses = []cyclonedxLicense{{Expression: r.License}}
+		}
+		for _, u := range r.Urls {
+			c.ExternalReferences = append(c.ExternalReferences, cyclonedxReference{Type: "distribution", Url: u})
+		}
+		if r.Homepage != "" {
+			c.ExternalReferences = append(c.ExternalReferences, cyclonedxReference{Type: "website", Url: r.Homepage})
+		}
+		for _, tag := range r.Tags {
+			c.Properties = append(c.Properties, cyclonedxProperty{Name: "grabit:tag", Value: tag})
+		}
+		bom.Components = append(bom.Components, c)
+	}
+	return bom, nil
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxPackage struct {
+	SPDXID           string         `json:"SPDXID"`
+	Name             string         `json:"name"`
+	VersionInfo      string         `json:"versionInfo,omitempty"`
+	DownloadLocation string         `json:"downloadLocation"`
+	FilesAnalyzed    bool           `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum `json:"checksums"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	LicenseDeclared  string         `json:"licenseDeclared"`
+	Supplier         string         `json:"supplier"`
+	Homepage         string         `json:"homepage,omitempty"`
+	Description      string         `json:"description,omitempty"`
+	Comment          string         `json:"comment,omitempty"`
+}
+
+type spdxRelationship struct {
+	SpdxElementId      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSpdxElement string `json:"relatedSpdxElement"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxDocument struct {
+	SpdxVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+// spdxSbom generates a SPDX 2.3 SBOM.
+func spdxSbom(l *Lock) (any, error) {
+	doc := spdxDocument{
+		SpdxVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              l.path,
+		DocumentNamespace: "https://spdx.org/spdxdocs/grabit-" + randomUuid(),
+		CreationInfo: spdxCreationInfo{
+			Created:  time.Now().UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: grabit-" + Version},
+		},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+	for i, r := range l.resources() {
+		algo, digest, err := integrityDigest(r.Integrity)
+		if err != nil {
+			return nil, err
+		}
+		p := spdxPackage{
+			SPDXID:           fmt.Sprintf("SPDXRef-Resource-%d", i+1),
+			Name:             r.name(),
+			VersionInfo:      r.Version,
+			DownloadLocation: "NOASSERTION",
+			Checksums:        []spdxChecksum{{Algorithm: strings.ToUpper(algo), ChecksumValue: digest}},
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			Supplier:         "NOASSERTION",
+			Homepage:         r.Homepage,
+			Description:      r.Description,
+		}
+		if u := r.firstUrl(); u != "" {
+			p.DownloadLocation = u
+		}
+		if r.License != "" {
+			p.LicenseDeclared = r.License
+		}
+		if r.Supplier != "" {
+			p.Supplier = "Organization: " + r.Supplier
+		}
+		if len(r.Tags) > 0 {
+			p.Comment = "tags: " + strings.Join(r.Tags, ", ")
+		}
+		doc.Packages = append(doc.Packages, p)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SpdxElementId:      doc.SPDXID,
+			RelationshipType:   "DESCRIBES",
+			RelatedSpdxElement: p.SPDXID,
+		})
+	}
+	return doc, nil
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sbomLockContent = `
+	[[Resource]]
+	Urls = ['http://localhost:123456/test.html', 'http://mirror:123456/test.html']
+	Integrity = 'sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE='
+	Tags = ['tag1', 'tag2']
+	Name = 'test'
+	Version = '1.0.0'
+	License = 'Apache-2.0'
+	Supplier = 'Example'
+	Homepage = 'https://example.com'
+
+	[[Resource]]
+	Urls = ['http://localhost:123456/other.tgz']
+	Integrity = 'sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE='
+	Filename = 'other.tar.gz'
+`
+
+func TestIntegrityDigest(t *testing.T) {
+	algo, digest, err := integrityDigest("sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE=")
+	assert.Nil(t, err)
+	assert.Equal(t, "sha256", algo)
+	assert.Equal(t, "bef57ec7f53a6d40beb640a780a639c83bc29ac8a9816f1fc6c5c6dcd93c4721", digest)
+	_, _, err = integrityDigest("md5-asdasd")
+	assert.NotNil(t, err)
+}
+
+func TestWriteSbomCycloneDX(t *testing.T) {
+	lock, err := NewLock(tmpFile(t, sbomLockContent), false)
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	err = lock.WriteSbom(&buf, "cyclonedx-json")
+	assert.Nil(t, err)
+	var bom cyclonedxBom
+	err = json.Unmarshal(buf.Bytes(), &bom)
+	assert.Nil(t, err)
+	assert.Equal(t, "CycloneDX", bom.BomFormat)
+	assert.Equal(t, 2, len(bom.Components))
+	c := bom.Components[0]
+	assert.Equal(t, "test", c.Name)
+	assert.Equal(t, "1.0.0", c.Version)
+	assert.Equal(t, []cyclonedxHash{{Alg: "SHA-256", Content: "bef57ec7f53a6d40beb640a780a639c83bc29ac8a9816f1fc6c5c6dcd93c4721"}}, c.Hashes)
+	assert.Equal(t, 3, len(c.ExternalReferences))
+	assert.Equal(t, cyclonedxReference{Type: "website", Url: "https://example.com"}, c.ExternalReferences[2])
+	assert.Equal(t, []cyclonedxProperty{{Name: "grabit:tag", Value: "tag1"}, {Name: "grabit:tag", Value: "tag2"}}, c.Properties)
+	assert.Equal(t, []cyclonedxLicense{{Expression: "Apache-2.0"}}, c.Licenses)
+	assert.Equal(t, "Example", c.Supplier.Name)
+	assert.Equal(t, "other.tar.gz", bom.Components[1].Name)
+	assert.Nil(t, bom.Components[1].Supplier)
+}
+
+func TestWriteSbomSpdx(t *testing.T) {
+	lock, err := NewLock(tmpFile(t, sbomLockContent), false)
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	err = lock.WriteSbom(&buf, "spdx-json")
+	assert.Nil(t, err)
+	var doc spdxDocument
+	err = json.Unmarshal(buf.Bytes(), &doc)
+	assert.Nil(t, err)
+	assert.Equal(t, "SPDX-2.3", doc.SpdxVersion)
+	assert.Equal(t, 2, len(doc.Packages))
+	assert.Equal(t, 2, len(doc.Relationships))
+	p := doc.Packages[0]
+	assert.Equal(t, "http://localhost:123456/test.html", p.DownloadLocation)
+	assert.Equal(t, []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: "bef57ec7f53a6d40beb640a780a639c83bc29ac8a9816f1fc6c5c6dcd93c4721"}}, p.Checksums)
+	assert.Equal(t, "1.0.0", p.VersionInfo)
+	assert.Equal(t, "https://example.com", p.Homepage)
+	assert.Equal(t, "Apache-2.0", p.LicenseDeclared)
+	assert.Equal(t, "Organization: Example", p.Supplier)
+	assert.Equal(t, "NOASSERTION", doc.Packages[1].LicenseDeclared)
+}
+
+func TestWriteSbomUnknownFormat(t *testing.T) {
+	lock, err := NewLock(tmpFile(t, sbomLockContent), false)
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	err = lock.WriteSbom(&buf, "xml")
+	assert.ErrorContains(t, err, "unknown SBOM format")
+}
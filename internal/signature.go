@@ -0,0 +1,393 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	_ "golang.org/x/crypto/blake2b"
+)
+
+const (
+	minisignComment = "untrusted comment:"
+	pgpKeyHeader    = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+	pgpKeyFooter    = "-----END PGP PUBLIC KEY BLOCK-----"
+	pgpSigHeader    = "-----BEGIN PGP SIGNATURE-----"
+)
+
+// publicKey is a key trusted to sign resources. Supported keys are:
+//   - minisign public keys,
+//   - PEM encoded ECDSA, Ed25519 and RSA public keys, used to verify
+//     base64 encoded signatures as produced by 'cosign sign-blob',
+//   - armored OpenPGP public keys, verified with gpg.
+type publicKey struct {
+	minisign []byte
+	pkix     crypto.PublicKey
+	pgp      string
+}
+
+// keyring holds the keys trusted to sign all the resources.
+var keyring []publicKey
+
+// LoadKeyring defines the keys trusted to sign all the resources from
+// the given file. A missing file is ignored.
+func LoadKeyring(path string) error {
+	if path == "" {
+		keyring = nil
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		keyring = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	keys, err := parsePublicKeys(string(content))
+	if err != nil {
+		return fmt.Errorf("invalid keyring '%s': %s", path, err)
+	}
+	keyring = keys
+	return nil
+}
+
+// DefaultKeyringPath returns $GRABIT_KEYRING if set and
+// grabit/keyring in the user configuration directory otherwise.
+func DefaultKeyringPath() string {
+	if path := os.Getenv("GRABIT_KEYRING"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "grabit", "keyring")
+}
+
+// ReadPublicKey returns the given public key, reading it from a file if
+// it is a path, after checking it is valid.
+func ReadPublicKey(key string) (string, error) {
+	if key == "" {
+		return "", nil
+	}
+	if content, err := os.ReadFile(key); err == nil {
+		key = string(content)
+	}
+	key = strings.TrimSpace(key)
+	_, err := parsePublicKeys(key)
+	if err != nil {
+		return "", fmt.Errorf("invalid public key: %s", err)
+	}
+	return key, nil
+}
+
+// cutBlocks removes the blocks delimited by the given header and footer
+// from text and returns them along with the remaining text.
+func cutBlocks(text string, header string, footer string) ([]string, string, error) {
+	var blocks []string
+	for {
+		start := strings.Index(text, header)
+		if start < 0 {
+			return blocks, text, nil
+		}
+		end := strings.Index(text[start:], footer)
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated block '%s'", header)
+		}
+		end += start + len(footer)
+		blocks = append(blocks, text[start:end])
+		text = text[:start] + text[end:]
+	}
+}
+
+// parsePublicKeys parses all the keys defined in the given text.
+func parsePublicKeys(text string) ([]publicKey, error) {
+	var keys []publicKey
+	pgpBlocks, text, err := cutBlocks(text, pgpKeyHeader, pgpKeyFooter)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range pgpBlocks {
+		keys = append(keys, publicKey{pgp: b})
+	}
+	pemBlocks, text, err := cutBlocks(text, "-----BEGIN PUBLIC KEY-----", "-----END PUBLIC KEY-----")
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range pemBlocks {
+		block, _ := pem.Decode([]byte(b))
+		if block == nil {
+			return nil, fmt.Errorf("invalid PEM public key")
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, publicKey{pkix: key})
+	}
+	// minisign public keys, one per line.
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, minisignComment) || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(key) != 42 || string(key[:2]) != "Ed" {
+			return nil, fmt.Errorf("unrecognized public key '%s'", line)
+		}
+		keys = append(keys, publicKey{minisign: key})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public key found")
+	}
+	return keys, nil
+}
+
+// verifySignature downloads the detached signature of the resource and
+// verifies the given file with it.
+func (l *Resource) verifySignature(path string, ctx context.Context) error {
+	if l.Signature == "" {
+		return nil
+	}
+	keys := keyring
+	if l.PublicKey != "" {
+		var err error
+		keys, err = parsePublicKeys(l.PublicKey)
+		if err != nil {
+			return fmt.Errorf("invalid public key: %s", err)
+		}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no trusted key to verify the signature '%s'", l.Signature)
+	}
+	sigPath, err := GetUrltoTempFile(l.Signature, l.Headers, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get signature: %s", err)
+	}
+	defer os.Remove(sigPath)
+	sig, err := os.ReadFile(sigPath)
+	if err != nil {
+		return err
+	}
+	err = verifyDetachedSignature(path, sig, keys, ctx)
+	if err != nil {
+		return withKind(KindSignature, fmt.Errorf("signature verification failed for '%s': %s", l.Signature, err))
+	}
+	log.Debug().Str("Signature", l.Signature).Msg("Signature verified")
+	return nil
+}
+
+// signedData is the data covered by a signature, held in memory or in a
+// file. Files are streamed through the digests signed by most schemes and
+// only read in memory by the ones signing the data itself.
+type signedData struct {
+	path    string
+	data    []byte
+	digests map[crypto.Hash][]byte
+}
+
+// digest returns the digest of the data with the given hash function.
+func (d *signedData) digest(h crypto.Hash) ([]byte, error) {
+	if digest, ok := d.digests[h]; ok {
+		return digest, nil
+	}
+	hasher := h.New()
+	if d.data != nil {
+		hasher.Write(d.data)
+	} else {
+		file, err := os.Open(d.path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		_, err = io.Copy(hasher, file)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if d.digests == nil {
+		d.digests = map[crypto.Hash][]byte{}
+	}
+	d.digests[h] = hasher.Sum(nil)
+	return d.digests[h], nil
+}
+
+// content returns the data itself, reading the file if needed.
+func (d *signedData) content() ([]byte, error) {
+	if d.data == nil {
+		data, err := os.ReadFile(d.path)
+		if err != nil {
+			return nil, err
+		}
+		d.data = data
+	}
+	return d.data, nil
+}
+
+// verifyDetachedSignature verifies that the given signature of a file was
+// made by one of the given keys.
+func verifyDetachedSignature(path string, sig []byte, keys []publicKey, ctx context.Context) error {
+	content := &signedData{path: path}
+	text := strings.TrimSpace(string(sig))
+	switch {
+	case strings.HasPrefix(text, minisignComment):
+		for _, k := range keys {
+			if k.minisign != nil && verifyMinisign(content, text, k.minisign) == nil {
+				return nil
+			}
+		}
+	case strings.HasPrefix(text, pgpSigHeader) || (len(sig) > 0 && sig[0]&0x80 != 0):
+		var armored []string
+		for _, k := range keys {
+			if k.pgp != "" {
+				armored = append(armored, k.pgp)
+			}
+		}
+		if len(armored) > 0 {
+			return verifyPgp(path, sig, armored, ctx)
+		}
+	default:
+		raw, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return fmt.Errorf("unrecognized signature format")
+		}
+		for _, k := range keys {
+			if k.pkix != nil && verifyPkix(content, raw, k.pkix) == nil {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no trusted key matches the signature")
+}
+
+// verifyMinisign verifies a minisign signature, including its trusted
+// comment, with a minisign public key. Legacy signatures sign the data
+// itself instead of its BLAKE2b digest and require reading it in memory.
+func verifyMinisign(content *signedData, text string, key []byte) error {
+	lines := strings.Split(text, "\n")
+	if len(lines) < 4 {
+		return fmt.Errorf("invalid minisign signature")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 74 {
+		return fmt.Errorf("invalid minisign signature")
+	}
+	if !bytes.Equal(sig[2:10], key[2:10]) {
+		return fmt.Errorf("minisign key id mismatch")
+	}
+	pk := ed25519.PublicKey(key[10:])
+	var message []byte
+	switch string(sig[:2]) {
+	case "ED":
+		message, err = content.digest(crypto.BLAKE2b_512)
+	case "Ed":
+		message, err = content.content()
+	default:
+		return fmt.Errorf("unsupported minisign algorithm")
+	}
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pk, message, sig[10:]) {
+		return fmt.Errorf("invalid minisign signature")
+	}
+	trusted, found := strings.CutPrefix(strings.TrimSpace(lines[2]), "trusted comment: ")
+	if !found {
+		return fmt.Errorf("missing minisign trusted comment")
+	}
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	signed := append(bytes.Clone(sig[10:]), []byte(trusted)...)
+	if err != nil || !ed25519.Verify(pk, signed, global) {
+		return fmt.Errorf("invalid minisign trusted comment signature")
+	}
+	return nil
+}
+
+// verifyPkix verifies a raw signature of the SHA-256 digest of a file
+// (or of the file itself for Ed25519 keys).
+func verifyPkix(content *signedData, sig []byte, key crypto.PublicKey) error {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		digest, err := content.digest(crypto.SHA256)
+		if err != nil {
+			return err
+		}
+		if ecdsa.VerifyASN1(k, digest, sig) {
+			return nil
+		}
+	case ed25519.PublicKey:
+		data, err := content.content()
+		if err != nil {
+			return err
+		}
+		if ed25519.Verify(k, data, sig) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		digest, err := content.digest(crypto.SHA256)
+		if err != nil {
+			return err
+		}
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, sig)
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+	return fmt.Errorf("invalid signature")
+}
+
+// verifyPgp verifies an OpenPGP signature with gpg and a temporary
+// keyring holding only the given keys.
+func verifyPgp(path string, sig []byte, keys []string, ctx context.Context) error {
+	home, err := os.MkdirTemp("", "grabit-gpg")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(home)
+	gpg := func(stdin io.Reader, args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, "gpg", append([]string{"--homedir", home, "--batch", "--no-tty"}, args...)...)
+		cmd.Stdin = stdin
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return "", fmt.Errorf("cannot run gpg: %s", err)
+			}
+		}
+		return string(out), err
+	}
+	_, err = gpg(strings.NewReader(strings.Join(keys, "\n")), "--import")
+	if err != nil {
+		return fmt.Errorf("cannot import OpenPGP keys: %s", err)
+	}
+	sigPath := filepath.Join(home, "signature")
+	err = os.WriteFile(sigPath, sig, 0600)
+	if err != nil {
+		return err
+	}
+	status, err := gpg(nil, "--status-fd", "1", "--verify", sigPath, path)
+	if err != nil || !strings.Contains(status, "[GNUPG:] VALIDSIG ") {
+		return fmt.Errorf("invalid OpenPGP signature")
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisignKey generates a minisign key pair and returns the public key
+// and a function signing content.
+func minisignKey(t *testing.T, prehash bool) (string, func([]byte) string) {
+	pk, sk, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyId := []byte("01234567")
+	public := base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyId...), pk...))
+	sign := func(content []byte) string {
+		alg := "Ed"
+		message := content
+		if prehash {
+			alg = "ED"
+			h := blake2b.Sum512(content)
+			message = h[:]
+		}
+		sig := ed25519.Sign(sk, message)
+		trusted := "timestamp:1700000000"
+		global := ed25519.Sign(sk, append(append([]byte{}, sig...), []byte(trusted)...))
+		return fmt.Sprintf("untrusted comment: signature\n%s\ntrusted comment: %s\n%s\n",
+			base64.StdEncoding.EncodeToString(append(append([]byte(alg), keyId...), sig...)),
+			trusted,
+			base64.StdEncoding.EncodeToString(global))
+	}
+	return "untrusted comment: minisign public key\n" + public, sign
+}
+
+func TestVerifyMinisign(t *testing.T) {
+	content := []byte(`abcdef`)
+	path := tmpFile(t, string(content))
+	for _, prehash := range []bool{false, true} {
+		public, sign := minisignKey(t, prehash)
+		keys, err := parsePublicKeys(public)
+		assert.Nil(t, err)
+		err = verifyDetachedSignature(path, []byte(sign(content)), keys, context.Background())
+		assert.Nil(t, err)
+		err = verifyDetachedSignature(path, []byte(sign([]byte(`tampered`))), keys, context.Background())
+		assert.NotNil(t, err)
+		otherPublic, _ := minisignKey(t, prehash)
+		otherKeys, err := parsePublicKeys(otherPublic)
+		assert.Nil(t, err)
+		err = verifyDetachedSignature(path, []byte(sign(content)), otherKeys, context.Background())
+		assert.NotNil(t, err)
+	}
+}
+
+func TestVerifyPkix(t *testing.T) {
+	content := []byte(`abcdef`)
+	path := tmpFile(t, string(content))
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&sk.PublicKey)
+	assert.Nil(t, err)
+	keys, err := parsePublicKeys(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	assert.Nil(t, err)
+	digest := sha256.Sum256(content)
+	sig, err := ecdsa.SignASN1(rand.Reader, sk, digest[:])
+	assert.Nil(t, err)
+	err = verifyDetachedSignature(path, []byte(base64.StdEncoding.EncodeToString(sig)), keys, context.Background())
+	assert.Nil(t, err)
+	digest = sha256.Sum256([]byte(`tampered`))
+	sig, err = ecdsa.SignASN1(rand.Reader, sk, digest[:])
+	assert.Nil(t, err)
+	err = verifyDetachedSignature(path, []byte(base64.StdEncoding.EncodeToString(sig)), keys, context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestVerifyPgp(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not available")
+	}
+	home := tmpDir(t)
+	gpg := func(args ...string) []byte {
+		cmd := exec.Command("gpg", append([]string{"--homedir", home, "--batch", "--passphrase", ""}, args...)...)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("gpg %s: %s", args[0], err)
+		}
+		return out
+	}
+	gpg("--quick-gen-key", "--pinentry-mode", "loopback", "test@example.com", "ed25519", "sign", "never")
+	public := gpg("--armor", "--export", "test@example.com")
+	path := tmpFile(t, "abcdef")
+	sigPath := filepath.Join(tmpDir(t), "file.asc")
+	gpg("--pinentry-mode", "loopback", "--armor", "--detach-sign", "--output", sigPath, path)
+	sig, err := os.ReadFile(sigPath)
+	assert.Nil(t, err)
+
+	keys, err := parsePublicKeys(string(public))
+	assert.Nil(t, err)
+	err = verifyDetachedSignature(path, sig, keys, context.Background())
+	assert.Nil(t, err)
+	err = verifyDetachedSignature(tmpFile(t, "tampered"), sig, keys, context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestParsePublicKeysInvalid(t *testing.T) {
+	_, err := parsePublicKeys("not a key")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unrecognized public key")
+	_, err = parsePublicKeys("")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no public key found")
+}
+
+func TestResourceSignature(t *testing.T) {
+	content := []byte(`abcdef`)
+	public, sign := minisignKey(t, true)
+	signature := sign(content)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if strings.HasSuffix(r.URL.Path, ".minisig") {
+			_, err = w.Write([]byte(signature))
+		} else {
+			_, err = w.Write(content)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	u := fmt.Sprintf("http://localhost:%d/test.html", port)
+	opts := ResourceOptions{Signature: u + ".minisig", PublicKey: public}
+	resource, err := NewResourceFromUrl([]string{u}, "sha256", opts)
+	assert.Nil(t, err)
+	_, err = resource.Download(tmpDir(t), NoFileMode, nil, context.Background())
+	assert.Nil(t, err)
+
+	// The signature must be made by a trusted key.
+	otherPublic, _ := minisignKey(t, true)
+	opts.PublicKey = otherPublic
+	_, err = NewResourceFromUrl([]string{u}, "sha256", opts)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "signature verification failed")
+
+	// Keys can come from the keyring.
+	opts.PublicKey = ""
+	_, err = NewResourceFromUrl([]string{u}, "sha256", opts)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no trusted key")
+	err = LoadKeyring(tmpFile(t, public))
+	assert.Nil(t, err)
+	defer LoadKeyring("")
+	_, err = NewResourceFromUrl([]string{u}, "sha256", opts)
+	assert.Nil(t, err)
+}
+
+func TestSignedDataStreamsDigests(t *testing.T) {
+	content := []byte(`abcdef`)
+	data := &signedData{path: tmpFile(t, string(content))}
+	digest, err := data.digest(crypto.SHA256)
+	assert.Nil(t, err)
+	expected := sha256.Sum256(content)
+	assert.Equal(t, expected[:], digest)
+	digest, err = data.digest(crypto.BLAKE2b_512)
+	assert.Nil(t, err)
+	expectedBlake := blake2b.Sum512(content)
+	assert.Equal(t, expectedBlake[:], digest)
+	// The file is never read in memory to compute its digests.
+	assert.Nil(t, data.data)
+}
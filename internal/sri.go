@@ -52,7 +52,7 @@ func checkIntegrityFromFile(path string, algo string, integrity string, u string
 		return fmt.Errorf("failed to compute ressource integrity: %s", err)
 	}
 	if computedIntegrity != integrity {
-		return fmt.Errorf("integrity mismatch for '%s': got '%s' expected '%s'", u, computedIntegrity, integrity)
+		return withKind(KindIntegrity, fmt.Errorf("integrity mismatch for '%s': got '%s' expected '%s'", u, computedIntegrity, integrity))
 	}
 	return nil
 }
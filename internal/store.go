@@ -0,0 +1,182 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Supported ways of materialising a resource from the store.
+const (
+	LinkCopy     = "copy"
+	LinkSymlink  = "symlink"
+	LinkHardlink = "hardlink"
+	LinkReflink  = "reflink"
+)
+
+var linkModes = []string{LinkCopy, LinkSymlink, LinkHardlink, LinkReflink}
+
+// Store is a content-addressed directory holding verified resources
+// so that they can be shared across several target directories.
+type Store struct {
+	dir  string
+	link string
+}
+
+// DefaultStoreDir returns the store location used when none is given
+// explicitly: $GRABIT_STORE if set, the user cache directory otherwise.
+func DefaultStoreDir() string {
+	if dir := os.Getenv("GRABIT_STORE"); dir != "" {
+		return dir
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "grabit", "store")
+	}
+	return filepath.Join(cache, "grabit", "store")
+}
+
+func NewStore(dir string, link string) (*Store, error) {
+	valid := false
+	for _, m := range linkModes {
+		if link == m {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, fmt.Errorf("unknown link mode '%s' (available modes: %s)", link, strings.Join(linkModes, ", "))
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(absDir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create store directory '%s': %s", absDir, err)
+	}
+	return &Store{dir: absDir, link: link}, nil
+}
+
+// objectPath returns the location in the store of the content
+// identified by the given SRI.
+func (s *Store) objectPath(integrity string) (string, error) {
+	algo, err := getAlgoFromIntegrity(integrity)
+	if err != nil {
+		return "", err
+	}
+	digest, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(integrity, algo+"-"))
+	if err != nil {
+		return "", fmt.Errorf("invalid SRI '%s': %s", integrity, err)
+	}
+	return filepath.Join(s.dir, algo, hex.EncodeToString(digest)), nil
+}
+
+// fetch makes sure the content identified by the given SRI is present in
+// the store, downloading it from u if needed, and returns its path.
+func (s *Store) fetch(u string, algo string, integrity string, headers map[string]string, ctx context.Context) (string, error) {
+	objPath, err := s.objectPath(integrity)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(objPath); err == nil {
+		err = checkIntegrityFromFile(objPath, algo, integrity, objPath)
+		if err == nil {
+			log.Debug().Str("URL", u).Str("Path", objPath).Msg("Found in store")
+			return objPath, nil
+		}
+		log.Warn().Str("Path", objPath).Msg("Corrupted store object, downloading again")
+	}
+	objDir := filepath.Dir(objPath)
+	err = os.MkdirAll(objDir, 0755)
+	if err != nil {
+		return "", err
+	}
+	// The store is shared by several processes, so the content is
+	// downloaded to a unique file before being moved to its final
+	// location.
+	tmp, err := os.CreateTemp(objDir, ".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	lpath := tmp.Name()
+	err = tmp.Close()
+	if err == nil {
+		_, err = getUrl(u, lpath, headers, ctx)
+	}
+	if err == nil {
+		err = checkIntegrityFromFile(lpath, algo, integrity, u)
+	}
+	if err == nil {
+		err = os.Rename(lpath, objPath)
+	}
+	if err != nil {
+		os.Remove(lpath)
+		return "", err
+	}
+	return objPath, nil
+}
+
+// materialise makes the store object available at the given path
+// according to the store link mode, with the given permissions if any.
+// Linked objects are shared by all the projects using the store, so they
+// are copied instead when permissions are given.
+func (s *Store) materialise(objPath string, resPath string, mode os.FileMode) error {
+	// Create the link next to its final location so that the call to
+	// os.Rename is atomic.
+	tmpPath := filepath.Join(filepath.Dir(resPath), fmt.Sprintf(".%s.tmp", filepath.Base(resPath)))
+	os.Remove(tmpPath)
+	link := s.link
+	if mode != NoFileMode && (link == LinkSymlink || link == LinkHardlink) {
+		log.Debug().Str("Path", resPath).Msgf("Copying instead of using a %s to set permissions %04o", link, mode)
+		link = LinkCopy
+	}
+	var err error
+	switch link {
+	case LinkSymlink:
+		err = os.Symlink(objPath, tmpPath)
+	case LinkHardlink:
+		err = os.Link(objPath, tmpPath)
+	case LinkReflink:
+		err = reflinkFile(objPath, tmpPath)
+		if err != nil {
+			log.Warn().Str("Path", resPath).Msgf("Reflink not available (%s), copying instead", err)
+			err = copyFile(objPath, tmpPath)
+		}
+	default:
+		err = copyFile(objPath, tmpPath)
+	}
+	if err == nil {
+		err = setFileMode(tmpPath, mode)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to %s '%s' to '%s': %s", link, objPath, resPath, err)
+	}
+	return os.Rename(tmpPath, resPath)
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	return errors.Join(err, out.Close())
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewStoreInvalidLink(t *testing.T) {
+	_, err := NewStore(tmpDir(t), "bogus")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unknown link mode")
+}
+
+func TestDownloadWithStore(t *testing.T) {
+	httpContent := []byte(`abcdef`)
+	requests := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, err := w.Write(httpContent)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	path := tmpFile(t, fmt.Sprintf(`
+		[[Resource]]
+		Urls = ['http://localhost:%d/test.html']
+		Integrity = 'sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE='`, port))
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	storeDir := tmpDir(t)
+	objPath := filepath.Join(storeDir, "sha256", "bef57ec7f53a6d40beb640a780a639c83bc29ac8a9816f1fc6c5c6dcd93c4721")
+
+	for _, link := range linkModes {
+		t.Run(link, func(t *testing.T) {
+			store, err := NewStore(storeDir, link)
+			assert.Nil(t, err)
+			dir := tmpDir(t)
+			_, err = lock.Download(dir, []string{}, []string{}, "", store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resFile := filepath.Join(dir, "test.html")
+			content, err := os.ReadFile(resFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, httpContent, content)
+			stats, err := os.Lstat(resFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			objStats, err := os.Stat(objPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch link {
+			case LinkSymlink:
+				assert.Equal(t, os.ModeSymlink, stats.Mode()&os.ModeSymlink)
+			case LinkHardlink:
+				assert.True(t, os.SameFile(stats, objStats))
+			default:
+				assert.True(t, stats.Mode().IsRegular())
+				assert.False(t, os.SameFile(stats, objStats))
+			}
+		})
+	}
+	// The content is only downloaded once.
+	assert.Equal(t, 1, requests)
+}
+
+func TestDownloadWithStorePerm(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`abcdef`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	path := tmpFile(t, fmt.Sprintf(`
+		[[Resource]]
+		Urls = ['http://localhost:%d/test.html']
+		Integrity = 'sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE='`, port))
+	lock, err := NewLock(path, false)
+	assert.Nil(t, err)
+	storeDir := tmpDir(t)
+	objPath := filepath.Join(storeDir, "sha256", "bef57ec7f53a6d40beb640a780a639c83bc29ac8a9816f1fc6c5c6dcd93c4721")
+
+	for _, link := range []string{LinkSymlink, LinkHardlink} {
+		t.Run(link, func(t *testing.T) {
+			store, err := NewStore(storeDir, link)
+			assert.Nil(t, err)
+			dir := tmpDir(t)
+			_, err = lock.Download(dir, []string{}, []string{}, "200", store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The shared object keeps its permissions and the installed
+			// file is a copy with the requested ones.
+			stats, err := os.Lstat(filepath.Join(dir, "test.html"))
+			assert.Nil(t, err)
+			assert.True(t, stats.Mode().IsRegular())
+			assert.Equal(t, os.FileMode(0200), stats.Mode().Perm())
+			objStats, err := os.Stat(objPath)
+			assert.Nil(t, err)
+			assert.False(t, os.SameFile(stats, objStats))
+			assert.NotEqual(t, os.FileMode(0200), objStats.Mode().Perm())
+		})
+	}
+}
+
+func TestStoreFetchConcurrent(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`abcdef`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port, server := httpHandler(handler)
+	defer server.Close()
+	u := fmt.Sprintf("http://localhost:%d/test.html", port)
+	integrity := "sha256-vvV+x/U6bUC+tkCngKY5yDvCmsipgW8fxsXG3Nk8RyE="
+	storeDir := tmpDir(t)
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			store, err := NewStore(storeDir, LinkCopy)
+			if err == nil {
+				_, err = store.fetch(u, "sha256", integrity, nil, context.Background())
+			}
+			errs <- err
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		assert.Nil(t, <-errs)
+	}
+	store, err := NewStore(storeDir, LinkCopy)
+	assert.Nil(t, err)
+	_, err = store.fetch(u, "sha256", "sha256-YWJj", nil, context.Background())
+	assert.NotNil(t, err)
+	entries, err := os.ReadDir(filepath.Join(storeDir, "sha256"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "bef57ec7f53a6d40beb640a780a639c83bc29ac8a9816f1fc6c5c6dcd93c4721", entries[0].Name())
+}
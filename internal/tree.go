@@ -0,0 +1,291 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// treeEntry is a file or a symbolic link of a tree.
+type treeEntry struct {
+	path    string
+	symlink bool
+	exec    bool
+	digest  []byte
+}
+
+// treeIntegrity computes the SRI of a set of tree entries. The hash covers,
+// for each entry sorted by slash-separated path:
+//
+//	<kind> <mode> <hex digest> <path>\x00
+//
+// where kind is 'file' or 'symlink', mode is '644' or '755' for files
+// depending on the executable bit and '777' for symbolic links, and the
+// digest is the hash of the file content or of the link target. Only the
+// executable bit is kept from the permissions so that the result does not
+// depend on the umask, and directories are implied by the paths of their
+// content, so empty directories are not part of the tree.
+func treeIntegrity(entries []treeEntry, algo string) (string, error) {
+	hash, err := NewHash(algo)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+	hasher := hash.hash()
+	for i, e := range entries {
+		if i > 0 && entries[i-1].path == e.path {
+			return "", fmt.Errorf("duplicate tree entry '%s'", e.path)
+		}
+		kind, mode := "file", "644"
+		if e.symlink {
+			kind, mode = "symlink", "777"
+		} else if e.exec {
+			mode = "755"
+		}
+		fmt.Fprintf(hasher, "%s %s %s %s\x00", kind, mode, hex.EncodeToString(e.digest), e.path)
+	}
+	return fmt.Sprintf("%s-%s", algo, base64.StdEncoding.EncodeToString(hasher.Sum(nil))), nil
+}
+
+// GetTreeIntegrity computes the SRI of the content of a directory.
+func GetTreeIntegrity(dir string, algo string) (string, error) {
+	hash, err := NewHash(algo)
+	if err != nil {
+		return "", err
+	}
+	var entries []treeEntry
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		entry := treeEntry{path: filepath.ToSlash(rel)}
+		hasher := hash.hash()
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			entry.symlink = true
+			hasher.Write([]byte(filepath.ToSlash(target)))
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			entry.exec = info.Mode().Perm()&0111 != 0
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(hasher, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported file type for '%s'", p)
+		}
+		entry.digest = hasher.Sum(nil)
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return treeIntegrity(entries, algo)
+}
+
+func checkIntegrityFromTree(dir string, algo string, integrity string) error {
+	computedIntegrity, err := GetTreeIntegrity(dir, algo)
+	if err != nil {
+		return fmt.Errorf("failed to compute tree integrity: %s", err)
+	}
+	if computedIntegrity != integrity {
+		return withKind(KindIntegrity, fmt.Errorf("tree integrity mismatch for '%s': got '%s' expected '%s'", dir, computedIntegrity, integrity))
+	}
+	return nil
+}
+
+// openArchive returns a tar reader over the given tar or gzipped tar file.
+func openArchive(archive string) (*tar.Reader, io.Closer, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(f)
+	magic, err := reader.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return tar.NewReader(gz), f, nil
+	}
+	return tar.NewReader(reader), f, nil
+}
+
+// archivePath validates the path of an archive entry and returns it in
+// its clean slash-separated form.
+func archivePath(name string) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("unsafe path '%s' in archive", name)
+	}
+	return clean, nil
+}
+
+// getTreeIntegrityFromArchive computes the SRI of the content of a tar
+// archive as GetTreeIntegrity would once the archive is extracted.
+func getTreeIntegrityFromArchive(archive string, algo string) (string, error) {
+	hash, err := NewHash(algo)
+	if err != nil {
+		return "", err
+	}
+	tr, closer, err := openArchive(archive)
+	if err != nil {
+		return "", err
+	}
+	defer closer.Close()
+	var entries []treeEntry
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid archive '%s': %s", archive, err)
+		}
+		name, err := archivePath(hdr.Name)
+		if err != nil {
+			return "", err
+		}
+		hasher := hash.hash()
+		entry := treeEntry{path: name}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeSymlink:
+			entry.symlink = true
+			hasher.Write([]byte(hdr.Linkname))
+		case tar.TypeReg:
+			entry.exec = hdr.Mode&0111 != 0
+			_, err = io.Copy(hasher, tr)
+			if err != nil {
+				return "", err
+			}
+		default:
+			return "", fmt.Errorf("unsupported entry type for '%s' in archive", hdr.Name)
+		}
+		entry.digest = hasher.Sum(nil)
+		entries = append(entries, entry)
+	}
+	return treeIntegrity(entries, algo)
+}
+
+// extractArchive extracts a tar or gzipped tar archive into an empty
+// directory. Entries escaping the directory are rejected.
+func extractArchive(archive string, dir string) error {
+	tr, closer, err := openArchive(archive)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid archive '%s': %s", archive, err)
+		}
+		name, err := archivePath(hdr.Name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		// Never write through a symbolic link created by a previous entry.
+		for parent := filepath.Dir(target); parent != dir; parent = filepath.Dir(parent) {
+			if info, err := os.Lstat(parent); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+				return fmt.Errorf("unsafe path '%s' in archive", hdr.Name)
+			}
+		}
+		switch hdr.Typeflag {
+		case tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeSymlink:
+			err = os.MkdirAll(filepath.Dir(target), 0755)
+			if err == nil {
+				err = os.Symlink(hdr.Linkname, target)
+			}
+		case tar.TypeReg:
+			err = os.MkdirAll(filepath.Dir(target), 0755)
+			if err == nil {
+				err = writeArchiveFile(tr, target, os.FileMode(hdr.Mode).Perm()|0600)
+			}
+		default:
+			err = fmt.Errorf("unsupported entry type for '%s' in archive", hdr.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func writeArchiveFile(r io.Reader, target string, mode os.FileMode) error {
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// isArchive returns true if the given file is a tar or gzipped tar archive.
+func isArchive(p string) bool {
+	tr, closer, err := openArchive(p)
+	if err != nil {
+		return false
+	}
+	defer closer.Close()
+	_, err = tr.Next()
+	return err == nil
+}
+
+// extractedName returns the name of the directory an archive is
+// extracted to.
+func extractedName(name string) string {
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar"} {
+		if strings.HasSuffix(name, ext) && len(name) > len(ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
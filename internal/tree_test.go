@@ -0,0 +1,129 @@
+// Copyright (c) 2023 Cisco Systems, Inc. and its affiliates
+// All rights reserved.
+
+package internal
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	mode     int64
+	content  string
+}
+
+// tmpArchive creates a gzipped tar archive with the given entries.
+func tmpArchive(t *testing.T, entries []tarEntry) string {
+	path := filepath.Join(tmpDir(t), "archive.tar.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Mode: e.mode}
+		if e.typeflag == tar.TypeSymlink {
+			hdr.Linkname = e.content
+		} else if e.typeflag == tar.TypeReg {
+			hdr.Size = int64(len(e.content))
+		}
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.typeflag == tar.TypeReg {
+			_, err = tw.Write([]byte(e.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+var sdkEntries = []tarEntry{
+	{"sdk/", tar.TypeDir, 0755, ""},
+	{"sdk/bin/tool", tar.TypeReg, 0755, "#!/bin/sh"},
+	{"sdk/README.md", tar.TypeReg, 0644, "readme"},
+	{"sdk/tool", tar.TypeSymlink, 0777, "bin/tool"},
+}
+
+func TestTreeIntegrity(t *testing.T) {
+	archive := tmpArchive(t, sdkEntries)
+	fromArchive, err := getTreeIntegrityFromArchive(archive, "sha256")
+	assert.Nil(t, err)
+
+	dir := tmpDir(t)
+	err = extractArchive(archive, dir)
+	assert.Nil(t, err)
+	fromDir, err := GetTreeIntegrity(dir, "sha256")
+	assert.Nil(t, err)
+	assert.Equal(t, fromArchive, fromDir)
+
+	// The executable bit is part of the tree.
+	err = os.Chmod(filepath.Join(dir, "sdk", "bin", "tool"), 0644)
+	assert.Nil(t, err)
+	err = checkIntegrityFromTree(dir, "sha256", fromArchive)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "tree integrity mismatch")
+}
+
+func TestExtractArchiveUnsafe(t *testing.T) {
+	tests := [][]tarEntry{
+		{{"../evil", tar.TypeReg, 0644, "evil"}},
+		{{"/evil", tar.TypeReg, 0644, "evil"}},
+		{{"link", tar.TypeSymlink, 0777, "/tmp"}, {"link/evil", tar.TypeReg, 0644, "evil"}},
+	}
+	for _, entries := range tests {
+		err := extractArchive(tmpArchive(t, entries), tmpDir(t))
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "unsafe path")
+	}
+}
+
+func TestExtractedName(t *testing.T) {
+	assert.Equal(t, "sdk-1.0", extractedName("sdk-1.0.tar.gz"))
+	assert.Equal(t, "sdk-1.0", extractedName("sdk-1.0.tgz"))
+	assert.Equal(t, "repo-0123456789ab", extractedName("repo-0123456789ab.tar"))
+	assert.Equal(t, "sdk", extractedName("sdk"))
+}
+
+func TestDownloadExtract(t *testing.T) {
+	archive := tmpArchive(t, sdkEntries)
+	resource, err := NewResourceFromUrl([]string{"file://" + archive}, "sha256", ResourceOptions{Extract: true})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, resource.TreeIntegrity)
+
+	dir := tmpDir(t)
+	_, err = resource.Download(dir, NoFileMode, nil, context.Background())
+	assert.Nil(t, err)
+	assert.FileExists(t, filepath.Join(dir, "archive", "sdk", "README.md"))
+	err = resource.Verify(dir)
+	assert.Nil(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "archive", "sdk", "README.md"), []byte("changed"), 0644)
+	assert.Nil(t, err)
+	err = resource.Verify(dir)
+	assert.NotNil(t, err)
+
+	_, err = NewResourceFromUrl([]string{"file://" + tmpFile(t, "abcdef")}, "sha256", ResourceOptions{Extract: true})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "cannot be extracted")
+}